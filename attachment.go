@@ -0,0 +1,155 @@
+package stgin
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const contentDispositionKey = "Content-Disposition"
+
+// AttachmentEntity is a ResponseEntity which is downloaded by the clients under the given file name,
+// using the Content-Disposition header (RFC 6266). Use Inline to let browsers display it instead (i.e., PDF files).
+type AttachmentEntity struct {
+	content interface {
+		ResponseEntity
+		servedEntity
+	}
+	filename string
+	inline   bool
+}
+
+// Attachment creates an entity which makes the clients download the file in the given path, saved as filename.
+// Just like File, it is streamed with range and conditional request support,
+// and it is responded with 404 not found if the file does not exist.
+func Attachment(path string, filename string) AttachmentEntity {
+	return AttachmentEntity{content: fileContent{path: path}, filename: filename}
+}
+
+// AttachmentFrom creates an entity which makes the clients download the content of the reader, saved as filename.
+// Use it to send generated content (i.e., reports or exports). The reader is consumed (and closed if it's an io.Closer)
+// when the response is written, so the entity must not be reused. If it is an io.ReadSeeker, range requests are supported.
+// An empty content type is sent as "application/octet-stream".
+func AttachmentFrom(reader io.Reader, filename string, contentType string) AttachmentEntity {
+	return AttachmentEntity{content: readerContent{reader: reader, contentType: contentType}, filename: filename}
+}
+
+// Inline returns a copy of the entity, which browsers display instead of downloading, while keeping the file name
+// in case the user saves it.
+func (ae AttachmentEntity) Inline() AttachmentEntity {
+	ae.inline = true
+	return ae
+}
+
+// Filename returns the name which the clients use to save the attachment.
+func (ae AttachmentEntity) Filename() string { return ae.filename }
+
+// ContentDisposition returns the Content-Disposition header sent with the entity.
+func (ae AttachmentEntity) ContentDisposition() string {
+	if ae.inline {
+		return contentDisposition("inline", ae.filename)
+	}
+	return contentDisposition("attachment", ae.filename)
+}
+
+func (ae AttachmentEntity) ContentType() string {
+	return ae.content.ContentType()
+}
+
+func (ae AttachmentEntity) Bytes() ([]byte, error) {
+	return ae.content.Bytes()
+}
+
+func (ae AttachmentEntity) serve(writer http.ResponseWriter, request *http.Request, statusCode int) error {
+	writer.Header().Set(contentDispositionKey, ae.ContentDisposition())
+	return ae.content.serve(writer, request, statusCode)
+}
+
+// contentDisposition formats a Content-Disposition header as described in RFC 6266.
+// The filename parameter holds an ASCII fallback of the name for older clients,
+// and the filename* parameter holds the actual name, encoded as described in RFC 8187.
+func contentDisposition(dispositionType string, filename string) string {
+	if filename == "" {
+		return dispositionType
+	}
+	return fmt.Sprintf(`%s; filename="%s"; filename*=UTF-8''%s`, dispositionType, asciiFilename(filename), encodeExtValue(filename))
+}
+
+// asciiFilename replaces the characters which cannot be used inside a quoted ASCII filename parameter.
+func asciiFilename(filename string) string {
+	var fallback strings.Builder
+	for _, char := range filename {
+		if char < ' ' || char > '~' || char == '"' || char == '\\' || char == '%' {
+			fallback.WriteByte('_')
+		} else {
+			fallback.WriteRune(char)
+		}
+	}
+	return fallback.String()
+}
+
+// encodeExtValue percent-encodes the UTF-8 bytes of the value, except for the attr-char characters of RFC 8187.
+func encodeExtValue(value string) string {
+	if !utf8.ValidString(value) {
+		value = strings.ToValidUTF8(value, "_")
+	}
+	var encoded strings.Builder
+	for i := 0; i < len(value); i++ {
+		char := value[i]
+		if isAttrChar(char) {
+			encoded.WriteByte(char)
+		} else {
+			_, _ = fmt.Fprintf(&encoded, "%%%02X", char)
+		}
+	}
+	return encoded.String()
+}
+
+func isAttrChar(char byte) bool {
+	return (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9') ||
+		strings.IndexByte("!#$&+-.^_`|~", char) >= 0
+}
+
+// readerContent is an entity which is written from a reader, it can only be written once.
+type readerContent struct {
+	reader      io.Reader
+	contentType string
+}
+
+func (rc readerContent) ContentType() string {
+	if rc.contentType == "" {
+		return octetStream
+	}
+	return rc.contentType
+}
+
+func (rc readerContent) Bytes() ([]byte, error) {
+	if closer, isCloser := rc.reader.(io.Closer); isCloser {
+		defer closer.Close()
+	}
+	return io.ReadAll(rc.reader)
+}
+
+func (rc readerContent) serve(writer http.ResponseWriter, request *http.Request, statusCode int) error {
+	if closer, isCloser := rc.reader.(io.Closer); isCloser {
+		defer closer.Close()
+	}
+	if writer.Header().Get(contentTypeKey) == "" {
+		writer.Header().Set(contentTypeKey, rc.ContentType())
+	}
+	if seeker, isSeeker := rc.reader.(io.ReadSeeker); isSeeker {
+		serveContent(writer, request, statusCode, "", time.Time{}, seeker)
+		return nil
+	}
+	writer.WriteHeader(statusCode)
+	if request.Method == http.MethodHead {
+		return nil
+	}
+	if _, err := io.Copy(writer, rc.reader); err != nil {
+		_ = stginLogger.ErrorF("error while writing response to client:\n\t%s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,489 @@
+package stgin
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// valueSource looks up the raw values of the given key inside some part of the request (i.e., query parameters).
+type valueSource = func(key string) ([]string, bool)
+
+// bindingSource is a part of the request that struct fields can be bound from, using the given struct tag.
+type bindingSource struct {
+	name   string // reported inside field errors, like "query" or "header"
+	tagKey string
+	lookup valueSource
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+var timeType = reflect.TypeOf(time.Time{})
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// time layouts that are tried in order, when binding values into time.Time fields.
+var bindingTimeLayouts = []string{time.RFC3339Nano, time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"}
+
+type bindingTag struct {
+	name         string
+	skip         bool
+	required     bool
+	hasDefault   bool
+	defaultValue string
+}
+
+// parseBindingTag reads tags like `qp:"page,required"` or `qp:"size,default=20"`.
+// If no name is given in the tag, the field name is used instead.
+func parseBindingTag(field reflect.StructField, tagKey string) bindingTag {
+	raw, _ := field.Tag.Lookup(tagKey)
+	if raw == "-" {
+		return bindingTag{skip: true}
+	}
+	parts := strings.Split(raw, ",")
+	tag := bindingTag{name: parts[0]}
+	if tag.name == "" {
+		tag.name = field.Name
+	}
+	for _, option := range parts[1:] {
+		switch {
+		case option == "required":
+			tag.required = true
+		case strings.HasPrefix(option, "default="):
+			tag.hasDefault = true
+			tag.defaultValue = strings.TrimPrefix(option, "default=")
+		}
+	}
+	return tag
+}
+
+func isNestedStruct(tpe reflect.Type) bool {
+	return tpe.Kind() == reflect.Struct && tpe != timeType && !reflect.PtrTo(tpe).Implements(textUnmarshalerType)
+}
+
+func isMissing(values []string) bool {
+	for _, value := range values {
+		if value != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldBinding finds the first source whose tag is defined on the given field.
+// If there's only one source, fields without the tag are looked up by their name.
+func fieldBinding(field reflect.StructField, sources []bindingSource) (bindingSource, bindingTag, bool) {
+	for _, source := range sources {
+		if _, tagged := field.Tag.Lookup(source.tagKey); tagged {
+			return source, parseBindingTag(field, source.tagKey), true
+		}
+	}
+	if len(sources) == 1 {
+		return sources[0], parseBindingTag(field, sources[0].tagKey), true
+	}
+	return bindingSource{}, bindingTag{name: field.Name}, false
+}
+
+// bindStruct fills the exported fields of the given struct value from the sources, looking each field up by the name
+// in its tag (prepended by prefix). Nested struct fields are looked up using their name and a dot as the prefix,
+// and embedded structs are flattened. It reports whether any field was bound, and appends every failure into errs.
+func bindStruct(value reflect.Value, sources []bindingSource, prefix string, errs *[]FieldError) bool {
+	tpe := value.Type()
+	var bound bool
+	for i := 0; i < tpe.NumField(); i++ {
+		field := tpe.Field(i)
+		// exported fields of unexported embedded structs are still bound
+		if !field.IsExported() && !(field.Anonymous && field.Type.Kind() == reflect.Struct) {
+			continue
+		}
+		source, tag, tagged := fieldBinding(field, sources)
+		if tag.skip {
+			continue
+		}
+		fieldValue := value.Field(i)
+		elemType := field.Type
+		if elemType.Kind() == reflect.Ptr {
+			elemType = elemType.Elem()
+		}
+
+		if isNestedStruct(elemType) {
+			nestedPrefix := prefix + tag.name + "."
+			if field.Anonymous || !tagged {
+				nestedPrefix = prefix
+			}
+			if bindNestedStruct(fieldValue, sources, nestedPrefix, errs) {
+				bound = true
+			}
+			continue
+		}
+		if !tagged {
+			continue
+		}
+
+		key := prefix + tag.name
+		values, found := source.lookup(key)
+		if !found || isMissing(values) {
+			switch {
+			case tag.hasDefault:
+				values = []string{tag.defaultValue}
+			case tag.required:
+				*errs = append(*errs, FieldError{Field: key, Source: source.name, Message: "is required"})
+				continue
+			default:
+				continue
+			}
+		}
+		if err := setFromStrings(fieldValue, values); err != nil {
+			*errs = append(*errs, FieldError{Field: key, Source: source.name, Message: err.Error()})
+			continue
+		}
+		bound = true
+	}
+	return bound
+}
+
+func bindNestedStruct(fieldValue reflect.Value, sources []bindingSource, prefix string, errs *[]FieldError) bool {
+	if fieldValue.Kind() != reflect.Ptr {
+		return bindStruct(fieldValue, sources, prefix, errs)
+	}
+	if !fieldValue.IsNil() {
+		return bindStruct(fieldValue.Elem(), sources, prefix, errs)
+	}
+	// only allocate the nested struct if something is bound into it
+	nested := reflect.New(fieldValue.Type().Elem())
+	if bindStruct(nested.Elem(), sources, prefix, errs) {
+		fieldValue.Set(nested)
+		return true
+	}
+	return false
+}
+
+// setFromStrings converts the given raw values into the type of v, and sets them into v.
+func setFromStrings(v reflect.Value, values []string) error {
+	tpe := v.Type()
+	if tpe.Kind() == reflect.Ptr {
+		elem := reflect.New(tpe.Elem())
+		if err := setFromStrings(elem.Elem(), values); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if tpe.Kind() == reflect.Slice && tpe.Elem().Kind() != reflect.Uint8 && !reflect.PtrTo(tpe).Implements(textUnmarshalerType) {
+		slice := reflect.MakeSlice(tpe, len(values), len(values))
+		for i, value := range values {
+			if err := setFromString(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+	if len(values) != 1 {
+		return fmt.Errorf("expected a single value, got %d", len(values))
+	}
+	return setFromString(v, values[0])
+}
+
+func setFromString(v reflect.Value, raw string) error {
+	tpe := v.Type()
+	if tpe.Kind() == reflect.Ptr {
+		elem := reflect.New(tpe.Elem())
+		if err := setFromString(elem.Elem(), raw); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if reflect.PtrTo(tpe).Implements(textUnmarshalerType) && tpe != timeType {
+		unmarshaler := reflect.New(tpe)
+		if err := unmarshaler.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw)); err != nil {
+			return fmt.Errorf("invalid value '%s', %v", raw, err)
+		}
+		v.Set(unmarshaler.Elem())
+		return nil
+	}
+	switch tpe {
+	case timeType:
+		for _, layout := range bindingTimeLayouts {
+			if parsed, err := time.Parse(layout, raw); err == nil {
+				v.Set(reflect.ValueOf(parsed))
+				return nil
+			}
+		}
+		return fmt.Errorf("expected a time (RFC3339 or yyyy-mm-dd), got '%s'", raw)
+	case durationType:
+		duration, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("expected a duration (i.e., 1h30m), got '%s'", raw)
+		}
+		v.SetInt(int64(duration))
+		return nil
+	}
+
+	switch tpe.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("expected a boolean, got '%s'", raw)
+		}
+		v.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(raw, 10, tpe.Bits())
+		if err != nil {
+			return fmt.Errorf("expected an integer of %d bits, got '%s'", tpe.Bits(), raw)
+		}
+		v.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(raw, 10, tpe.Bits())
+		if err != nil {
+			return fmt.Errorf("expected an unsigned integer of %d bits, got '%s'", tpe.Bits(), raw)
+		}
+		v.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(raw, tpe.Bits())
+		if err != nil {
+			return fmt.Errorf("expected a floating point number, got '%s'", raw)
+		}
+		v.SetFloat(parsed)
+	case reflect.Slice:
+		if tpe.Elem().Kind() == reflect.Uint8 {
+			v.SetBytes([]byte(raw))
+			return nil
+		}
+		fallthrough
+	default:
+		return fmt.Errorf("unsupported field type %s", tpe.String())
+	}
+	return nil
+}
+
+const defaultMultipartMemory = 32 << 20
+
+func mediaTypeOf(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+func isJsonMediaType(mediaType string) bool {
+	return mediaType == applicationJson || strings.HasSuffix(mediaType, "+json")
+}
+
+func isXmlMediaType(mediaType string) bool {
+	return mediaType == applicationXml || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml")
+}
+
+func isFormMediaType(mediaType string) bool {
+	return mediaType == formUrlEncoded || mediaType == "multipart/form-data"
+}
+
+func (request RequestContext) pathSource() bindingSource {
+	return bindingSource{name: "path", tagKey: "path", lookup: func(key string) ([]string, bool) {
+		value, found := request.PathParams.Get(key)
+		return []string{value}, found
+	}}
+}
+
+func (request RequestContext) headerSource() bindingSource {
+	return bindingSource{name: "header", tagKey: "header", lookup: func(key string) ([]string, bool) {
+		values := request.Headers.Values(key)
+		return values, len(values) != 0
+	}}
+}
+
+func (request RequestContext) cookieSource() bindingSource {
+	return bindingSource{name: "cookie", tagKey: "cookie", lookup: func(key string) ([]string, bool) {
+		if request.Underlying == nil {
+			return nil, false
+		}
+		cookie, err := request.Underlying.Cookie(key)
+		if err != nil {
+			return nil, false
+		}
+		return []string{cookie.Value}, true
+	}}
+}
+
+func (request RequestContext) formSource() bindingSource {
+	return bindingSource{name: "form", tagKey: "form", lookup: func(key string) ([]string, bool) {
+		if request.Underlying == nil || request.Underlying.PostForm == nil {
+			return nil, false
+		}
+		values, found := request.Underlying.PostForm[key]
+		return values, found
+	}}
+}
+
+// bindBody decodes the request body into a, based on the request's content type.
+// Form bodies are parsed, so that they can be bound using `form` tags.
+func (request RequestContext) bindBody(a any) error {
+	mediaType := mediaTypeOf(request.Headers.Get(contentTypeKey))
+	if isFormMediaType(mediaType) {
+		if request.Underlying == nil {
+			return nil
+		}
+		var err error
+		if mediaType == "multipart/form-data" {
+			err = request.Underlying.ParseMultipartForm(defaultMultipartMemory)
+		} else {
+			err = request.Underlying.ParseForm()
+		}
+		if err != nil {
+			return ParseError{tpe: "form", details: err.Error()}
+		}
+		return nil
+	}
+	if request.Body == nil {
+		return nil
+	}
+	body := request.Body()
+	if body == nil {
+		return nil
+	}
+	bytes, readErr := body.fillAndGetBytes()
+	if readErr != nil {
+		return *readErr
+	}
+	if len(bytes) == 0 {
+		return nil
+	}
+	isRegistered := body.registry().lookup(mediaType) != nil
+	switch {
+	case mediaType == "":
+		return body.decodeInto(a, "JSON", applicationJson)
+	case isJsonMediaType(mediaType):
+		return body.decodeInto(a, "JSON", mediaType)
+	case isXmlMediaType(mediaType):
+		return body.decodeInto(a, "XML", mediaType)
+	case isRegistered:
+		return body.decodeInto(a, mediaType, mediaType)
+	default:
+		return HttpError(http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported content type '%s'", mediaType))
+	}
+}
+
+// isBoundFromRequest reports whether the field is bound from a part of the request other than the body.
+func isBoundFromRequest(field reflect.StructField, sources []bindingSource) bool {
+	_, tag, tagged := fieldBinding(field, sources)
+	return tagged && !tag.skip
+}
+
+// detachBoundFields prepares a copy of a struct for the body to be decoded into, so that decoding does not write
+// through the pointers it shares with the original struct: pointers to nested structs are copied, and the pointers of
+// the fields bound from the other parts of the request are cleared.
+func detachBoundFields(value reflect.Value, sources []bindingSource) {
+	tpe := value.Type()
+	for i := 0; i < tpe.NumField(); i++ {
+		field := tpe.Field(i)
+		if !field.IsExported() && !(field.Anonymous && field.Type.Kind() == reflect.Struct) {
+			continue
+		}
+		fieldValue := value.Field(i)
+		elemType := field.Type
+		if elemType.Kind() == reflect.Ptr {
+			elemType = elemType.Elem()
+		}
+		switch {
+		case isNestedStruct(elemType) && field.Type.Kind() == reflect.Ptr:
+			if !fieldValue.IsNil() {
+				nested := reflect.New(elemType)
+				nested.Elem().Set(fieldValue.Elem())
+				detachBoundFields(nested.Elem(), sources)
+				fieldValue.Set(nested)
+			}
+		case isNestedStruct(elemType):
+			detachBoundFields(fieldValue, sources)
+		case field.Type.Kind() == reflect.Ptr && isBoundFromRequest(field, sources):
+			fieldValue.Set(reflect.Zero(field.Type))
+		}
+	}
+}
+
+// restoreBoundFields reverts the fields of the decoded struct which are bound from the other parts of the request
+// to their original values, so that the body cannot set them when they're missing from the request.
+func restoreBoundFields(value reflect.Value, original reflect.Value, sources []bindingSource) {
+	tpe := value.Type()
+	for i := 0; i < tpe.NumField(); i++ {
+		field := tpe.Field(i)
+		if !field.IsExported() && !(field.Anonymous && field.Type.Kind() == reflect.Struct) {
+			continue
+		}
+		fieldValue, originalValue := value.Field(i), original.Field(i)
+		elemType := field.Type
+		if elemType.Kind() == reflect.Ptr {
+			elemType = elemType.Elem()
+		}
+		switch {
+		case isNestedStruct(elemType) && field.Type.Kind() == reflect.Ptr:
+			if fieldValue.IsNil() {
+				continue
+			}
+			if originalValue.IsNil() {
+				originalValue = reflect.Zero(elemType)
+			} else {
+				originalValue = originalValue.Elem()
+			}
+			restoreBoundFields(fieldValue.Elem(), originalValue, sources)
+		case isNestedStruct(elemType):
+			restoreBoundFields(fieldValue, originalValue, sources)
+		case isBoundFromRequest(field, sources):
+			fieldValue.Set(originalValue)
+		}
+	}
+}
+
+// SafeBind receives a pointer to a struct, and fills it from all the parts of the request.
+// The body is decoded based on the request's content type (JSON, XML or forms),
+// and then fields tagged with `path`, `qp`, `header`, `cookie` or `form` are looked up in the corresponding part of
+// the request, supporting the same types and tag options as QueryToObj.
+// The body only fills the fields which are not bound from the other parts of the request, so that clients cannot
+// set them through the body. Bodies of unsupported content types are reported as 415 unsupported media type (an error
+// implementing StatusCoder). If anything cannot be bound, a BindingError describing all the failures is returned,
+// otherwise the result is validated using its `validate` tags.
+func (request RequestContext) SafeBind(a any) error {
+	value := reflect.ValueOf(a)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return errors.New("passed a non-pointer or a pointer to a non-struct value to Bind function, please use struct pointers instead")
+	}
+	sources := []bindingSource{
+		request.pathSource(),
+		request.querySource(),
+		request.headerSource(),
+		request.cookieSource(),
+		request.formSource(),
+	}
+	var fieldErrors []FieldError
+	decoded := reflect.New(value.Elem().Type())
+	decoded.Elem().Set(value.Elem())
+	detachBoundFields(decoded.Elem(), sources)
+	if err := request.bindBody(decoded.Interface()); err != nil {
+		if _, isStatusCoder := err.(StatusCoder); isStatusCoder {
+			return err
+		}
+		fieldErrors = append(fieldErrors, FieldError{Source: "body", Message: err.Error()})
+	}
+	restoreBoundFields(decoded.Elem(), value.Elem(), sources)
+	value.Elem().Set(decoded.Elem())
+	bindStruct(value.Elem(), sources, "", &fieldErrors)
+	if len(fieldErrors) != 0 {
+		return BindingError{Errors: fieldErrors}
+	}
+	return validateWithNames(a, "path", "qp", "header", "cookie", "form", "json", "xml")
+}
+
+// Bind does the same thing as SafeBind, but panics in case any error happens.
+// The default error handler completes these panics with a 400 bad request, describing all the failures.
+func (request RequestContext) Bind(a any) {
+	if err := request.SafeBind(a); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,272 @@
+package stgin
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Codec encodes and decodes the entities of a media type, both for request bodies and response entities.
+// Implementations must be safe to be used concurrently.
+type Codec interface {
+	Marshal(a any) ([]byte, error)
+	Unmarshal(data []byte, a any) error
+}
+
+// JSONCodec is the built-in application/json codec, based on encoding/json.
+// The zero value behaves just like json.Marshal and json.Unmarshal.
+type JSONCodec struct {
+	// Indent indents the encoded entities using the given string for each level, if it's not empty.
+	Indent string
+	// DisallowUnknownFields rejects objects which have keys that do not match any fields of the destination struct.
+	DisallowUnknownFields bool
+	// UseNumber decodes numbers into interface values as json.Number instead of float64.
+	UseNumber bool
+	// DisableHTMLEscaping stops escaping <, > and & inside JSON strings.
+	DisableHTMLEscaping bool
+}
+
+func (codec JSONCodec) Marshal(a any) ([]byte, error) {
+	if codec.Indent == "" && !codec.DisableHTMLEscaping {
+		return json.Marshal(a)
+	}
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetIndent("", codec.Indent)
+	encoder.SetEscapeHTML(!codec.DisableHTMLEscaping)
+	if err := encoder.Encode(a); err != nil {
+		return nil, err
+	}
+	// the encoder terminates each value with a new line, which json.Marshal does not
+	return bytes.TrimSuffix(buffer.Bytes(), []byte{'\n'}), nil
+}
+
+func (codec JSONCodec) Unmarshal(data []byte, a any) error {
+	if !codec.DisallowUnknownFields && !codec.UseNumber {
+		return json.Unmarshal(data, a)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if codec.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if codec.UseNumber {
+		decoder.UseNumber()
+	}
+	if err := decoder.Decode(a); err != nil {
+		return err
+	}
+	// just like json.Unmarshal, anything but white space after the value is rejected
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("invalid data after top-level value")
+	}
+	return nil
+}
+
+// XMLCodec is the built-in application/xml codec, based on encoding/xml.
+// The zero value behaves just like xml.Marshal and xml.Unmarshal.
+type XMLCodec struct {
+	// Indent indents the encoded entities using the given string for each level, if it's not empty.
+	Indent string
+}
+
+func (codec XMLCodec) Marshal(a any) ([]byte, error) {
+	if codec.Indent == "" {
+		return xml.Marshal(a)
+	}
+	return xml.MarshalIndent(a, "", codec.Indent)
+}
+
+func (codec XMLCodec) Unmarshal(data []byte, a any) error {
+	return xml.Unmarshal(data, a)
+}
+
+// TextCodec is the built-in text/plain codec. Strings and byte slices are encoded as is, encoding.TextMarshaler and
+// fmt.Stringer values are encoded as their text, and other values cannot be encoded (so negotiation moves on to
+// the other codecs). Text is decoded into string pointers, byte slice pointers and encoding.TextUnmarshaler values.
+type TextCodec struct{}
+
+func (codec TextCodec) Marshal(a any) ([]byte, error) {
+	switch value := a.(type) {
+	case string:
+		return []byte(value), nil
+	case []byte:
+		return value, nil
+	case encoding.TextMarshaler:
+		return value.MarshalText()
+	case fmt.Stringer:
+		return []byte(value.String()), nil
+	default:
+		return nil, fmt.Errorf("cannot encode %T as text", a)
+	}
+}
+
+func (codec TextCodec) Unmarshal(data []byte, a any) error {
+	switch value := a.(type) {
+	case *string:
+		*value = string(data)
+	case *[]byte:
+		*value = append([]byte{}, data...)
+	case encoding.TextUnmarshaler:
+		return value.UnmarshalText(data)
+	default:
+		return fmt.Errorf("cannot decode text into %T", a)
+	}
+	return nil
+}
+
+// CodecRegistry holds the codecs of media types, which are used to decode request bodies (i.e., SafeJSONInto and Bind),
+// and encode response entities (i.e., Json, Xml and Negotiate). Registries can be set on servers and controllers,
+// the controller's registry is used if it's set, then the server's, and then DefaultCodecs.
+// All the methods are safe to be called concurrently.
+type CodecRegistry struct {
+	mutex      sync.RWMutex
+	codecs     map[string]Codec
+	mediaTypes []string
+}
+
+// builtinCodecs are used for the media types which are not registered in a registry.
+var builtinCodecs = map[string]Codec{
+	applicationJson:           JSONCodec{},
+	applicationXml:            XMLCodec{},
+	plainText:                 TextCodec{},
+	applicationYaml:           YAMLCodec{},
+	"application/x-yaml":      YAMLCodec{},
+	"text/yaml":               YAMLCodec{},
+	textCsv:                   CSVCodec{},
+	applicationMsgpack:        MsgpackCodec{},
+	"application/x-msgpack":   MsgpackCodec{},
+	"application/vnd.msgpack": MsgpackCodec{},
+	formUrlEncoded:            FormCodec{},
+}
+
+// NewCodecRegistry returns a registry holding the built-in JSON, XML and plain text codecs.
+// The other built-in codecs (YAML, CSV, MessagePack and forms) are used for their media types unless they're overridden,
+// but they're not negotiated unless they're registered.
+func NewCodecRegistry() *CodecRegistry {
+	registry := &CodecRegistry{}
+	for _, mediaType := range []string{applicationJson, applicationXml, plainText} {
+		_ = registry.Register(mediaType, builtinCodecs[mediaType])
+	}
+	return registry
+}
+
+// DefaultCodecs is the registry used by servers and controllers which have no registries of their own.
+var DefaultCodecs = NewCodecRegistry()
+
+// Register registers the codec for the media type (like "application/json"), replacing the existing codec if any.
+// The order of registration is the order of preference when negotiating the response's media type.
+func (registry *CodecRegistry) Register(mediaType string, codec Codec) error {
+	if codec == nil {
+		return fmt.Errorf("cannot register nil as the codec of '%s'", mediaType)
+	}
+	parsed, params, err := mime.ParseMediaType(mediaType)
+	if err != nil || len(params) != 0 || strings.Contains(parsed, "*") {
+		return fmt.Errorf("invalid media type '%s'", mediaType)
+	}
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if registry.codecs == nil {
+		registry.codecs = make(map[string]Codec)
+	}
+	if _, found := registry.codecs[parsed]; !found {
+		registry.mediaTypes = append(registry.mediaTypes, parsed)
+	}
+	registry.codecs[parsed] = codec
+	return nil
+}
+
+// Codec returns the codec registered for the media type (parameters like charset are ignored).
+// Media types with the +json and +xml suffixes (and text/xml) fall back to the JSON and XML codecs.
+func (registry *CodecRegistry) Codec(mediaType string) (Codec, bool) {
+	mediaType = mediaTypeOf(mediaType)
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	if codec, found := registry.codecs[mediaType]; found {
+		return codec, true
+	}
+	switch {
+	case isJsonMediaType(mediaType):
+		codec, found := registry.codecs[applicationJson]
+		return codec, found
+	case isXmlMediaType(mediaType):
+		codec, found := registry.codecs[applicationXml]
+		return codec, found
+	}
+	return nil, false
+}
+
+// MediaTypes returns the registered media types, in the order of registration.
+func (registry *CodecRegistry) MediaTypes() []string {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	return append([]string{}, registry.mediaTypes...)
+}
+
+// lookup returns the codec of the media type, falling back to the built-in codecs, so that registries without
+// JSON, XML or text codecs can still encode the built-in entities.
+func (registry *CodecRegistry) lookup(mediaType string) Codec {
+	if registry != nil {
+		if codec, found := registry.Codec(mediaType); found {
+			return codec
+		}
+	}
+	switch {
+	case isJsonMediaType(mediaType):
+		return builtinCodecs[applicationJson]
+	case isXmlMediaType(mediaType):
+		return builtinCodecs[applicationXml]
+	}
+	return builtinCodecs[mediaType]
+}
+
+// responseCodecs returns the codecs which the response entities can be negotiated between, in the order of preference.
+func (registry *CodecRegistry) responseCodecs() []responseCodec {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	codecs := make([]responseCodec, 0, len(registry.mediaTypes))
+	for _, mediaType := range registry.mediaTypes {
+		codecs = append(codecs, responseCodec{mediaType: mediaType, marshal: registry.codecs[mediaType].Marshal})
+	}
+	return codecs
+}
+
+// resolveCodecs returns the first registry which is set.
+func resolveCodecs(registries ...*CodecRegistry) *CodecRegistry {
+	for _, registry := range registries {
+		if registry != nil {
+			return registry
+		}
+	}
+	return DefaultCodecs
+}
+
+type codecsContextKey struct{}
+
+// withCodecs binds the registry to the request, so that the entities written into its response can use it.
+func withCodecs(request *http.Request, codecs *CodecRegistry) *http.Request {
+	return request.WithContext(context.WithValue(request.Context(), codecsContextKey{}, codecs))
+}
+
+// codecsOf returns the registry bound to the request, or DefaultCodecs.
+func codecsOf(request *http.Request) *CodecRegistry {
+	if request != nil {
+		if codecs, found := request.Context().Value(codecsContextKey{}).(*CodecRegistry); found {
+			return codecs
+		}
+	}
+	return DefaultCodecs
+}
+
+// encodedEntity is implemented by entities which are encoded using the codecs of the server or controller.
+type encodedEntity interface {
+	encode(codecs *CodecRegistry) ([]byte, error)
+}
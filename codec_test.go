@@ -0,0 +1,140 @@
+package stgin
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONCodec(t *testing.T) {
+	value := map[string]string{"html": "<b>"}
+	if encoded, _ := (JSONCodec{}).Marshal(value); string(encoded) != `{"html":"\u003cb\u003e"}` {
+		t.Fatalf("zero codec should behave like json.Marshal, got %s", encoded)
+	}
+	if encoded, _ := (JSONCodec{DisableHTMLEscaping: true}).Marshal(value); string(encoded) != `{"html":"<b>"}` {
+		t.Fatalf("html was escaped, got %s", encoded)
+	}
+	if encoded, _ := (JSONCodec{Indent: "  "}).Marshal(greetResponse{Greeting: "hi"}); string(encoded) != "{\n  \"greeting\": \"hi\"\n}" {
+		t.Fatalf("value was not indented, got %q", encoded)
+	}
+
+	var greeting greetResponse
+	strict := JSONCodec{DisallowUnknownFields: true}
+	if err := strict.Unmarshal([]byte(`{"greeting":"hi","name":"x"}`), &greeting); err == nil {
+		t.Fatal("unknown fields were not rejected")
+	}
+	if err := strict.Unmarshal([]byte(`{"greeting":"hi"} {}`), &greeting); err == nil {
+		t.Fatal("data after the value was not rejected")
+	}
+	if err := strict.Unmarshal([]byte(` {"greeting":"hi"} `), &greeting); err != nil || greeting.Greeting != "hi" {
+		t.Fatalf("could not decode the value: %v", err)
+	}
+	var number any
+	if err := (JSONCodec{UseNumber: true}).Unmarshal([]byte(`12345678901234567890`), &number); err != nil {
+		t.Fatal(err)
+	}
+	if _, isNumber := number.(json.Number); !isNumber {
+		t.Fatalf("expected json.Number, got %T", number)
+	}
+}
+
+func TestTextCodec(t *testing.T) {
+	values := []any{"text", []byte("text"), time.Second, net.IPv4(10, 0, 0, 1)}
+	for i, expected := range []string{"text", "text", "1s", "10.0.0.1"} {
+		if encoded, err := (TextCodec{}).Marshal(values[i]); err != nil || string(encoded) != expected {
+			t.Errorf("expected %q for %T, got %q (%v)", expected, values[i], encoded, err)
+		}
+	}
+	if _, err := (TextCodec{}).Marshal(greetResponse{Greeting: "hi"}); err == nil {
+		t.Fatal("structs should not be encoded as text")
+	}
+}
+
+func TestCodecRegistry(t *testing.T) {
+	registry := NewCodecRegistry()
+	if err := registry.Register("application/json; charset=utf-8", JSONCodec{}); err == nil {
+		t.Fatal("media types with parameters should not be registered")
+	}
+	if err := registry.Register("text/*", TextCodec{}); err == nil {
+		t.Fatal("media ranges should not be registered")
+	}
+	if err := registry.Register("application/json", JSONCodec{Indent: "\t"}); err != nil {
+		t.Fatal(err)
+	}
+	if mediaTypes := registry.MediaTypes(); strings.Join(mediaTypes, ",") != "application/json,application/xml,text/plain" {
+		t.Fatalf("replacing a codec should keep its order, got %v", mediaTypes)
+	}
+	if codec, found := registry.Codec("application/problem+json; charset=utf-8"); !found || codec != (JSONCodec{Indent: "\t"}) {
+		t.Fatal("+json media types should use the json codec")
+	}
+	if _, found := registry.Codec("text/xml"); !found {
+		t.Fatal("text/xml should use the xml codec")
+	}
+	if _, found := registry.Codec("application/yaml"); found {
+		t.Fatal("found a codec which is not registered")
+	}
+}
+
+type upperCodec struct{}
+
+func (upperCodec) Marshal(a any) ([]byte, error) { return []byte(strings.ToUpper(a.(string))), nil }
+func (upperCodec) Unmarshal([]byte, any) error   { return nil }
+
+func TestServerAndControllerCodecs(t *testing.T) {
+	api := func(request RequestContext) Status {
+		var greeting greetResponse
+		request.Body().JSONInto(&greeting)
+		return Ok(Json(greeting))
+	}
+	indented := NewController("Indented", "indented")
+	indented.AddRoutes(POST("/greet", api))
+	indentedCodecs := NewCodecRegistry()
+	_ = indentedCodecs.Register(applicationJson, JSONCodec{Indent: " "})
+	indented.SetCodecs(indentedCodecs)
+	strict := NewController("Strict", "strict")
+	strict.AddRoutes(POST("/greet", api), GET("/upper", func(RequestContext) Status {
+		return Ok(Negotiate("hello"))
+	}), POST("/bind", func(request RequestContext) Status {
+		var greeting greetResponse
+		if err := request.SafeBind(&greeting); err != nil {
+			return BadRequest(Text(err.Error()))
+		}
+		return Ok(Text(greeting.Greeting))
+	}))
+	server := &Server{Controllers: []*Controller{indented, strict}, notFoundAction: notFoundDefaultAction, errorAction: errorAction}
+	serverCodecs := NewCodecRegistry()
+	_ = serverCodecs.Register(applicationJson, JSONCodec{DisallowUnknownFields: true})
+	_ = serverCodecs.Register("text/x-upper", upperCodec{})
+	_ = serverCodecs.Register("application/vnd.greeting", JSONCodec{})
+	server.SetCodecs(serverCodecs)
+	handler := server.HttpHandler()
+	serve := func(method, path, contentType, accept, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.Header.Set(contentTypeKey, contentType)
+		request.Header.Set("Accept", accept)
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	response := serve("POST", "/indented/greet", applicationJson, "", `{"greeting":"hi","name":"x"}`)
+	if response.Code != http.StatusOK || response.Body.String() != "{\n \"greeting\": \"hi\"\n}" {
+		t.Fatalf("controller codecs were not used, got %d: %q", response.Code, response.Body.String())
+	}
+	if response = serve("POST", "/strict/greet", applicationJson, "", `{"greeting":"hi","name":"x"}`); response.Code != http.StatusBadRequest {
+		t.Fatalf("server codecs were not used to decode the body, got %d", response.Code)
+	}
+	if response = serve("GET", "/strict/upper", "", "text/x-upper", ""); response.Body.String() != "HELLO" || response.Header().Get(contentTypeKey) != "text/x-upper" {
+		t.Fatalf("registered codecs were not negotiated, got %q %v", response.Body.String(), response.Header())
+	}
+	if response = serve("POST", "/strict/bind", "application/vnd.greeting", "", `{"greeting":"hi"}`); response.Body.String() != "hi" {
+		t.Fatalf("registered codecs were not used to bind the body, got %d: %q", response.Code, response.Body.String())
+	}
+	if response = serve("POST", "/strict/bind", "application/toml", "", "greeting = \"hi\""); response.Code != http.StatusBadRequest {
+		t.Fatalf("unregistered media types should not be bound, got %d", response.Code)
+	}
+}
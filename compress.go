@@ -0,0 +1,367 @@
+package stgin
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const defaultCompressionMinSize = 1024
+
+// ContentEncoder compresses responses using a content coding, like gzip.
+// Codings which are not available in the standard library (like brotli) can be added by providing their writers.
+type ContentEncoder struct {
+	// Name is the content coding, as it appears in the Accept-Encoding and Content-Encoding headers (i.e., "br").
+	Name string
+	// NewWriter returns a writer which compresses everything written into it into the given writer,
+	// the compressed stream must be complete once the returned writer is closed.
+	NewWriter func(writer io.Writer) (io.WriteCloser, error)
+}
+
+// pooledWriter returns the compressor into its pool once it's closed.
+type pooledWriter struct {
+	compressor interface {
+		io.WriteCloser
+		Flush() error
+	}
+	pool *sync.Pool
+}
+
+func (pw *pooledWriter) Write(bytes []byte) (int, error) { return pw.compressor.Write(bytes) }
+
+func (pw *pooledWriter) Flush() error { return pw.compressor.Flush() }
+
+func (pw *pooledWriter) Close() error {
+	err := pw.compressor.Close()
+	pw.pool.Put(pw.compressor)
+	return err
+}
+
+// GzipEncoder returns a gzip ContentEncoder with the given compression level (see compress/gzip).
+func GzipEncoder(level int) ContentEncoder {
+	if _, err := gzip.NewWriterLevel(io.Discard, level); err != nil {
+		printStacktrace("")
+		panic(err)
+	}
+	pool := &sync.Pool{New: func() any {
+		compressor, _ := gzip.NewWriterLevel(io.Discard, level)
+		return compressor
+	}}
+	return ContentEncoder{Name: "gzip", NewWriter: func(writer io.Writer) (io.WriteCloser, error) {
+		compressor := pool.Get().(*gzip.Writer)
+		compressor.Reset(writer)
+		return &pooledWriter{compressor: compressor, pool: pool}, nil
+	}}
+}
+
+// DeflateEncoder returns a deflate ContentEncoder with the given compression level (see compress/flate).
+func DeflateEncoder(level int) ContentEncoder {
+	if _, err := flate.NewWriter(io.Discard, level); err != nil {
+		printStacktrace("")
+		panic(err)
+	}
+	pool := &sync.Pool{New: func() any {
+		compressor, _ := flate.NewWriter(io.Discard, level)
+		return compressor
+	}}
+	return ContentEncoder{Name: "deflate", NewWriter: func(writer io.Writer) (io.WriteCloser, error) {
+		compressor := pool.Get().(*flate.Writer)
+		compressor.Reset(writer)
+		return &pooledWriter{compressor: compressor, pool: pool}, nil
+	}}
+}
+
+var defaultEncoders = []ContentEncoder{
+	GzipEncoder(gzip.DefaultCompression),
+	DeflateEncoder(flate.DefaultCompression),
+}
+
+// incompressibleTypes are the media types (or prefixes of them) which are already compressed.
+var incompressibleTypes = []string{
+	"image/", "video/", "audio/", "font/woff", "font/woff2",
+	"application/zip", "application/gzip", "application/x-gzip", "application/x-bzip2", "application/x-xz",
+	"application/zstd", "application/x-7z-compressed", "application/x-rar-compressed", "application/pdf",
+}
+
+// CompressionConfig is the configuration of response compression.
+type CompressionConfig struct {
+	// Encoders are the content codings used to compress the responses, in the order of preference.
+	// Defaults to gzip and deflate.
+	Encoders []ContentEncoder
+	// MinSize is the minimum size of the responses (in bytes) which are compressed. Defaults to 1024,
+	// negative values compress the responses of any size. Streamed responses are compressed once they're flushed.
+	MinSize int
+	// SkipContentTypes are the media types (or prefixes of them, like "text/") which are never compressed,
+	// in addition to the already compressed types like images, videos and archives.
+	SkipContentTypes []string
+}
+
+func (config *CompressionConfig) minSize() int {
+	if config.MinSize == 0 {
+		return defaultCompressionMinSize
+	}
+	return config.MinSize
+}
+
+func (config *CompressionConfig) encoders() []ContentEncoder {
+	if len(config.Encoders) == 0 {
+		return defaultEncoders
+	}
+	return config.Encoders
+}
+
+func (config *CompressionConfig) compressible(contentType string) bool {
+	mediaType := mediaTypeOf(contentType)
+	if mediaType == "image/svg+xml" {
+		return true
+	}
+	for _, skipped := range [][]string{incompressibleTypes, config.SkipContentTypes} {
+		for _, prefix := range skipped {
+			if strings.HasPrefix(mediaType, strings.ToLower(prefix)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// negotiate chooses the encoder based on the Accept-Encoding header, preferring the encoders' order on equal qualities.
+func (config *CompressionConfig) negotiate(acceptEncoding string) *ContentEncoder {
+	accepted := parseQualityList(acceptEncoding)
+	var chosen *ContentEncoder
+	var chosenQuality float64
+	encoders := config.encoders()
+	for i := range encoders {
+		quality, found := 0.0, false
+		for _, value := range accepted {
+			if value.value == strings.ToLower(encoders[i].Name) {
+				quality, found = value.quality, true
+				break
+			}
+		}
+		if !found {
+			for _, value := range accepted {
+				if value.value == "*" {
+					quality = value.quality
+					break
+				}
+			}
+		}
+		if quality > chosenQuality {
+			chosen, chosenQuality = &encoders[i], quality
+		}
+	}
+	return chosen
+}
+
+func resolveCompression(configs ...*CompressionConfig) *CompressionConfig {
+	for _, config := range configs {
+		if config != nil {
+			return config
+		}
+	}
+	return nil
+}
+
+const (
+	compressionPending = iota
+	compressionSkipped
+	compressionStarted
+)
+
+// compressWriter buffers the response until it's big enough (or flushed) to decide whether it should be compressed.
+// Responses which are not compressed are written as they are, with an accurate Content-Length.
+type compressWriter struct {
+	http.ResponseWriter
+	request     *http.Request
+	config      *CompressionConfig
+	encoder     *ContentEncoder
+	statusCode  int
+	wroteHeader bool
+	state       int
+	buffer      []byte
+	compressor  io.WriteCloser
+	hijacked    bool
+}
+
+func newCompressWriter(writer http.ResponseWriter, request *http.Request, config *CompressionConfig) *compressWriter {
+	return &compressWriter{
+		ResponseWriter: writer,
+		request:        request,
+		config:         config,
+		encoder:        config.negotiate(request.Header.Get("Accept-Encoding")),
+	}
+}
+
+func (cw *compressWriter) WriteHeader(statusCode int) {
+	if statusCode < 200 {
+		// informational responses are written as they are, switching protocols leaves the connection to the handler
+		if statusCode == http.StatusSwitchingProtocols {
+			cw.state = compressionSkipped
+		}
+		cw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	if cw.wroteHeader {
+		return
+	}
+	cw.statusCode, cw.wroteHeader = statusCode, true
+	headers := cw.Header()
+	// partial responses and ranges are computed over the identity representation, so they're kept as they are
+	if statusCode == http.StatusNoContent || statusCode == http.StatusNotModified || statusCode == http.StatusPartialContent ||
+		cw.request.Method == http.MethodHead || headers.Get("Content-Encoding") != "" || headers.Get("Content-Range") != "" {
+		cw.skip()
+		return
+	}
+	if length, err := strconv.Atoi(headers.Get("Content-Length")); err == nil && cw.config.minSize() > 0 && length < cw.config.minSize() {
+		cw.varyIfCompressible()
+		cw.skip()
+	}
+}
+
+func (cw *compressWriter) Write(bytes []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	switch cw.state {
+	case compressionSkipped:
+		return cw.ResponseWriter.Write(bytes)
+	case compressionStarted:
+		return cw.compressor.Write(bytes)
+	}
+	cw.buffer = append(cw.buffer, bytes...)
+	if len(cw.buffer) >= cw.config.minSize() {
+		if err := cw.start(); err != nil {
+			return 0, err
+		}
+	}
+	return len(bytes), nil
+}
+
+// varyIfCompressible adds Vary: Accept-Encoding to the responses which are compressed for some clients,
+// so that caches do not serve them to the others.
+func (cw *compressWriter) varyIfCompressible() bool {
+	headers := cw.Header()
+	if headers.Get(contentTypeKey) == "" && len(cw.buffer) != 0 {
+		// the same as net/http, which cannot sniff the content type once it's compressed
+		headers.Set(contentTypeKey, http.DetectContentType(cw.buffer))
+	}
+	if !cw.config.compressible(headers.Get(contentTypeKey)) {
+		return false
+	}
+	addVary(headers, "Accept-Encoding")
+	return true
+}
+
+// skip writes the buffered response as it is.
+func (cw *compressWriter) skip() error {
+	cw.state = compressionSkipped
+	cw.ResponseWriter.WriteHeader(cw.statusCode)
+	if len(cw.buffer) == 0 {
+		return nil
+	}
+	_, err := cw.ResponseWriter.Write(cw.buffer)
+	cw.buffer = nil
+	return err
+}
+
+// start decides on the pending response, and compresses it if possible.
+func (cw *compressWriter) start() error {
+	if !cw.varyIfCompressible() || cw.encoder == nil {
+		return cw.skip()
+	}
+	compressor, err := cw.encoder.NewWriter(cw.ResponseWriter)
+	if err != nil {
+		return err
+	}
+	headers := cw.Header()
+	headers.Set("Content-Encoding", cw.encoder.Name)
+	headers.Del("Content-Length")
+	headers.Del("Accept-Ranges")
+	// the compressed representation is not byte-for-byte the same
+	if etag := headers.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		headers.Set("ETag", "W/"+etag)
+	}
+	cw.state, cw.compressor = compressionStarted, compressor
+	cw.ResponseWriter.WriteHeader(cw.statusCode)
+	_, err = compressor.Write(cw.buffer)
+	cw.buffer = nil
+	return err
+}
+
+// Flush compresses streamed responses regardless of their size, since there is no telling how big they get.
+func (cw *compressWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.state == compressionPending {
+		if err := cw.start(); err != nil {
+			return
+		}
+	}
+	if flusher, isFlusher := cw.compressor.(interface{ Flush() error }); isFlusher && cw.state == compressionStarted {
+		if err := flusher.Flush(); err != nil {
+			return
+		}
+	}
+	if flusher, isFlusher := cw.ResponseWriter.(http.Flusher); isFlusher {
+		flusher.Flush()
+	}
+}
+
+// ReadFrom keeps the underlying writer's optimizations (like sendfile) available for the responses which are not
+// compressed, the others are copied into the compressor.
+func (cw *compressWriter) ReadFrom(reader io.Reader) (int64, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if readerFrom, isReaderFrom := cw.ResponseWriter.(io.ReaderFrom); isReaderFrom && cw.state == compressionSkipped {
+		return readerFrom.ReadFrom(reader)
+	}
+	// hides ReadFrom from io.Copy, which would call it again
+	return io.Copy(struct{ io.Writer }{cw}, reader)
+}
+
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, isHijacker := cw.ResponseWriter.(http.Hijacker)
+	if !isHijacker {
+		return nil, nil, errors.New("the response writer does not support hijacking the connection")
+	}
+	cw.hijacked = true
+	return hijacker.Hijack()
+}
+
+// finish writes the rest of the response once the handler is done.
+func (cw *compressWriter) finish() {
+	if cw.hijacked || !cw.wroteHeader {
+		return
+	}
+	switch cw.state {
+	case compressionPending:
+		cw.varyIfCompressible()
+		cw.Header().Set("Content-Length", strconv.Itoa(len(cw.buffer)))
+		_ = cw.skip()
+	case compressionStarted:
+		if err := cw.compressor.Close(); err != nil {
+			_ = stginLogger.ErrorF("error while compressing the response:\n\t%s", err.Error())
+		}
+	}
+}
+
+// serveCompressed runs the handler with a compressing writer, if compression is enabled.
+func serveCompressed(config *CompressionConfig, handler http.HandlerFunc, writer http.ResponseWriter, request *http.Request) {
+	if config == nil {
+		handler(writer, request)
+		return
+	}
+	compressed := newCompressWriter(writer, request, config)
+	handler(compressed, request)
+	compressed.finish()
+}
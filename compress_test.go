@@ -0,0 +1,219 @@
+package stgin
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompressionConfig_Negotiate(t *testing.T) {
+	config := &CompressionConfig{}
+	cases := map[string]string{
+		"":                          "",
+		"gzip":                      "gzip",
+		"deflate, gzip":             "gzip",
+		"gzip;q=0.5, deflate":       "deflate",
+		"br":                        "",
+		"*":                         "gzip",
+		"*, gzip;q=0":               "deflate",
+		"identity, GZIP;q=0.1":      "gzip",
+		"gzip;q=0, deflate;q=0, br": "",
+	}
+	for header, expected := range cases {
+		encoder := config.negotiate(header)
+		if (encoder == nil && expected != "") || (encoder != nil && encoder.Name != expected) {
+			t.Errorf("expected %q for %q, got %v", expected, header, encoder)
+		}
+	}
+	brotli := ContentEncoder{Name: "br", NewWriter: func(writer io.Writer) (io.WriteCloser, error) { return nil, nil }}
+	config = &CompressionConfig{Encoders: []ContentEncoder{brotli, GzipEncoder(gzip.BestSpeed)}}
+	if encoder := config.negotiate("gzip, br"); encoder == nil || encoder.Name != "br" {
+		t.Fatalf("expected the preferred encoder, got %v", encoder)
+	}
+}
+
+func compressedGet(t *testing.T, url string, headers map[string]string) (*http.Response, string) {
+	request, _ := http.NewRequest(http.MethodGet, url, nil)
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	var reader io.Reader = response.Body
+	switch response.Header.Get("Content-Encoding") {
+	case "gzip":
+		if reader, err = gzip.NewReader(response.Body); err != nil {
+			t.Fatal(err)
+		}
+	case "deflate":
+		reader = flate.NewReader(response.Body)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response, string(body)
+}
+
+func TestCompression(t *testing.T) {
+	large := strings.Repeat("compressible ", 200)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "large.txt"), []byte(large), 0644); err != nil {
+		t.Fatal(err)
+	}
+	controller := NewController("Compression", "")
+	controller.AddRoutes(
+		GET("/large", func(RequestContext) Status { return Ok(Text(large)) }),
+		GET("/small", func(RequestContext) Status { return Ok(Text("small")) }),
+		GET("/image", func(RequestContext) Status {
+			return Ok(Reader("image/png", strings.NewReader(large)))
+		}),
+		GET("/file", func(RequestContext) Status {
+			return Ok(Attachment(filepath.Join(dir, "large.txt"), "large.txt"))
+		}),
+		GET("/empty", func(RequestContext) Status { return CreateResponse(http.StatusNoContent, Empty()) }),
+	)
+	controller.EnableCompression(CompressionConfig{})
+	server := streamServer(controller)
+	defer server.Close()
+
+	response, body := compressedGet(t, server.URL+"/large", map[string]string{"Accept-Encoding": "gzip"})
+	if response.Header.Get("Content-Encoding") != "gzip" || body != large {
+		t.Fatalf("response was not compressed, got %q", response.Header.Get("Content-Encoding"))
+	}
+	if response.ContentLength == int64(len(large)) || response.Header.Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("unexpected headers of the compressed response: %v", response.Header)
+	}
+
+	response, body = compressedGet(t, server.URL+"/large", map[string]string{"Accept-Encoding": "deflate"})
+	if response.Header.Get("Content-Encoding") != "deflate" || body != large {
+		t.Fatalf("response was not deflated, got %q", response.Header.Get("Content-Encoding"))
+	}
+
+	response, body = compressedGet(t, server.URL+"/large", map[string]string{"Accept-Encoding": "identity"})
+	if response.Header.Get("Content-Encoding") != "" || body != large {
+		t.Fatalf("response was compressed for a client which does not accept it: %v", response.Header)
+	}
+	if response.Header.Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("compressible response did not vary on Accept-Encoding")
+	}
+
+	response, body = compressedGet(t, server.URL+"/small", map[string]string{"Accept-Encoding": "gzip"})
+	if response.Header.Get("Content-Encoding") != "" || body != "small" || response.ContentLength != 5 {
+		t.Fatalf("small response was compressed: %v", response.Header)
+	}
+
+	response, body = compressedGet(t, server.URL+"/image", map[string]string{"Accept-Encoding": "gzip"})
+	if response.Header.Get("Content-Encoding") != "" || response.Header.Get("Vary") != "" || body != large {
+		t.Fatalf("already compressed type was compressed: %v", response.Header)
+	}
+
+	response, body = compressedGet(t, server.URL+"/file", map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=0-11"})
+	if response.StatusCode != http.StatusPartialContent || response.Header.Get("Content-Encoding") != "" || body != "compressible" {
+		t.Fatalf("range response was broken, got %d %q: %v", response.StatusCode, body, response.Header)
+	}
+	if response.ContentLength != 12 {
+		t.Fatalf("expected the content length of the range, got %d", response.ContentLength)
+	}
+
+	response, body = compressedGet(t, server.URL+"/file", map[string]string{"Accept-Encoding": "gzip"})
+	if response.Header.Get("Content-Encoding") != "gzip" || response.Header.Get("Accept-Ranges") != "" || body != large {
+		t.Fatalf("file was not compressed: %v", response.Header)
+	}
+
+	response, _ = compressedGet(t, server.URL+"/empty", map[string]string{"Accept-Encoding": "gzip"})
+	if response.StatusCode != http.StatusNoContent || response.Header.Get("Content-Encoding") != "" {
+		t.Fatalf("no content response was compressed: %v", response.Header)
+	}
+}
+
+func TestCompression_Stream(t *testing.T) {
+	proceed := make(chan struct{})
+	controller := NewController("CompressedStreams", "")
+	controller.AddRoutes(GET("/events", func(RequestContext) Status {
+		return Ok(Stream("text/plain", func(writer io.Writer) error {
+			_, _ = fmt.Fprintln(writer, "first")
+			<-proceed
+			_, err := fmt.Fprintln(writer, "second")
+			return err
+		}))
+	}))
+	server := &Server{Controllers: []*Controller{controller}, notFoundAction: notFoundDefaultAction, errorAction: errorAction}
+	server.EnableCompression(CompressionConfig{})
+	testServer := httptest.NewServer(server.HttpHandler())
+	defer testServer.Close()
+
+	request, _ := http.NewRequest(http.MethodGet, testServer.URL+"/events", nil)
+	request.Header.Set("Accept-Encoding", "gzip")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("stream was not compressed: %v", response.Header)
+	}
+	reader, err := gzip.NewReader(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bufio.NewReader(reader)
+	if line, _ := lines.ReadString('\n'); line != "first\n" {
+		t.Fatalf("first chunk was not flushed, got: %q", line)
+	}
+	close(proceed)
+	if line, _ := lines.ReadString('\n'); line != "second\n" {
+		t.Fatalf("second chunk was not received, got: %q", line)
+	}
+}
+
+// readerFromRecorder records whether the response was read from a reader, like net/http's sendfile.
+type readerFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom bool
+}
+
+func (rfr *readerFromRecorder) ReadFrom(reader io.Reader) (int64, error) {
+	rfr.readFrom = true
+	return io.Copy(rfr.ResponseRecorder, reader)
+}
+
+func TestCompression_ReadFrom(t *testing.T) {
+	large := strings.Repeat("compressible ", 200)
+	for _, content := range []string{"small", large} {
+		request := httptest.NewRequest(http.MethodGet, "/file", nil)
+		request.Header.Set("Accept-Encoding", "gzip")
+		recorder := &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+		writer := newCompressWriter(recorder, request, &CompressionConfig{})
+		writer.Header().Set(contentTypeKey, plainText)
+		writer.Header().Set("Content-Length", fmt.Sprint(len(content)))
+		if _, err := writer.ReadFrom(strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+		writer.finish()
+		compressed := recorder.Header().Get("Content-Encoding") == "gzip"
+		if compressed == recorder.readFrom || compressed != (content == large) {
+			t.Fatalf("unexpected response of %d bytes, compressed: %v, read from: %v", len(content), compressed, recorder.readFrom)
+		}
+		body := recorder.Body.String()
+		if compressed {
+			reader, _ := gzip.NewReader(recorder.Body)
+			decompressed, _ := io.ReadAll(reader)
+			body = string(decompressed)
+		}
+		if body != content {
+			t.Fatalf("unexpected body of %d bytes, got %d bytes", len(content), len(body))
+		}
+	}
+}
@@ -16,6 +16,8 @@ type Controller struct {
 	responseListeners []ResponseListener
 	apiListeners      []APIListener
 	interrupts        []Interrupt
+	codecs            *CodecRegistry
+	compression       *CompressionConfig
 }
 
 // NewController returns a pointer to a newly created controller with the given name and path prefixes.
@@ -32,6 +34,9 @@ func (controller *Controller) AddRoutes(routes ...Route) {
 		route.controller = controller
 		route.Path = normalizePath(controller.prefix + route.Path)
 		route.correspondingRegex = getRoutePatternRegexOrPanic(route.Path)
+		if route.isStaticDir() {
+			route.Action = route.static.api(route.staticPrefix())
+		}
 		controller.routes = append(controller.routes, route)
 	}
 }
@@ -55,6 +60,18 @@ func (controller *Controller) AddAPIListeners(listeners ...APIListener) {
 	controller.apiListeners = append(controller.apiListeners, listeners...)
 }
 
+// SetCodecs sets the codecs used to decode the request bodies and encode the response entities of the controller,
+// instead of the server's codecs.
+func (controller *Controller) SetCodecs(codecs *CodecRegistry) {
+	controller.codecs = codecs
+}
+
+// EnableCompression compresses the responses of the controller based on the Accept-Encoding header of the requests,
+// instead of the server's compression configuration.
+func (controller *Controller) EnableCompression(config CompressionConfig) {
+	controller.compression = &config
+}
+
 // SetTimeout registers a timeout interrupt into the controller.
 func (controller *Controller) SetTimeout(timeout time.Duration) {
 	controller.RegisterInterrupts(TimeoutInterrupt(timeout))
@@ -69,7 +86,7 @@ func (controller *Controller) RegisterInterrupts(interrupts ...Interrupt) {
 func (controller *Controller) executeInternal(request *http.Request) Status {
 	var headers http.Header
 	if request.Header == nil {
-		headers = emptyHeaders
+		headers = http.Header{}
 	} else {
 		headers = request.Header
 	}
@@ -112,22 +129,29 @@ func (controller *Controller) executeInternal(request *http.Request) Status {
 	var done bool
 	go func() {
 		var result Status
+		var queryErr error
 		for _, route := range controller.routes {
 			matches, pathParams := route.acceptsAndPathParams(request)
-			if matches && acceptsAllQueries(route.expectedQueries, request.URL.Query()) {
-				rc.PathParams = PathParams{pathParams}
-				done = true
-				result = route.Action(rc)
-				break
+			if !matches {
+				continue
+			}
+			queries, err := resolveQueries(route.expectedQueries, request.URL.Query())
+			if err != nil {
+				if queryErr == nil {
+					queryErr = err
+				}
+				continue
 			}
+			rc.PathParams = PathParams{pathParams}
+			rc.QueryParams = rc.QueryParams.withResolved(queries)
+			done = true
+			result = route.Action(rc)
+			break
 		}
-		if !done {
-			result = NotFound(Json(&generalFailureMessage{
-				StatusCode: 404,
-				Path:       request.URL.Path,
-				Message:    "not found",
-				Method:     request.Method,
-			}))
+		if !done && queryErr != nil {
+			result = invalidQueryAction(queryErr)(rc)
+		} else if !done {
+			result = failureResponse(rc, http.StatusNotFound, "not found")
 		}
 
 		for _, modifier := range controller.responseListeners {
@@ -0,0 +1,162 @@
+package stgin
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"reflect"
+)
+
+const textCsv = "text/csv"
+
+// CSVCodec is the built-in text/csv codec, which encodes [][]string values as they are, and slices of structs
+// as a header row followed by a row for each struct. Columns are named by the `csv` tags of the fields
+// (supporting "-"), or their names, and decoded the same way as query parameters (see QueryToObj).
+type CSVCodec struct {
+	// Comma is the field delimiter. Defaults to ','.
+	Comma rune
+	// UseCRLF terminates the rows with \r\n instead of \n.
+	UseCRLF bool
+}
+
+type csvColumn struct {
+	name  string
+	index int
+}
+
+func csvColumns(tpe reflect.Type) []csvColumn {
+	var columns []csvColumn
+	for i := 0; i < tpe.NumField(); i++ {
+		field := tpe.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if tag := parseBindingTag(field, "csv"); !tag.skip {
+			columns = append(columns, csvColumn{name: tag.name, index: i})
+		}
+	}
+	return columns
+}
+
+// csvStructType returns the struct type of the slice's items, which can also be pointers to structs.
+func csvStructType(tpe reflect.Type) (reflect.Type, bool) {
+	if tpe.Kind() != reflect.Slice && tpe.Kind() != reflect.Array {
+		return nil, false
+	}
+	elem := tpe.Elem()
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	return elem, elem.Kind() == reflect.Struct
+}
+
+func (codec CSVCodec) Marshal(a any) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	if codec.Comma != 0 {
+		writer.Comma = codec.Comma
+	}
+	writer.UseCRLF = codec.UseCRLF
+	if records, isRecords := a.([][]string); isRecords {
+		err := writer.WriteAll(records)
+		return buffer.Bytes(), err
+	}
+	value := reflect.ValueOf(a)
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	structType, isStructs := csvStructType(value.Type())
+	if !isStructs {
+		return nil, fmt.Errorf("cannot encode %T as CSV, expected [][]string or a slice of structs", a)
+	}
+	columns := csvColumns(structType)
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.name
+	}
+	records := [][]string{header}
+	for i := 0; i < value.Len(); i++ {
+		item := value.Index(i)
+		if item.Kind() == reflect.Ptr {
+			if item.IsNil() {
+				continue
+			}
+			item = item.Elem()
+		}
+		record := make([]string, len(columns))
+		for j, column := range columns {
+			cell, err := formatScalar(item.Field(column.index))
+			if err != nil {
+				return nil, fmt.Errorf("column '%s': %w", column.name, err)
+			}
+			record[j] = cell
+		}
+		records = append(records, record)
+	}
+	err := writer.WriteAll(records)
+	return buffer.Bytes(), err
+}
+
+func (codec CSVCodec) Unmarshal(data []byte, a any) error {
+	reader := csv.NewReader(bytes.NewReader(data))
+	if codec.Comma != 0 {
+		reader.Comma = codec.Comma
+	}
+	if records, isRecords := a.(*[][]string); isRecords {
+		read, err := reader.ReadAll()
+		if err != nil {
+			return err
+		}
+		*records = read
+		return nil
+	}
+	target := reflect.ValueOf(a)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return fmt.Errorf("cannot decode into non-pointer %T", a)
+	}
+	structType, isStructs := csvStructType(target.Elem().Type())
+	if !isStructs || target.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("cannot decode CSV into %T, expected *[][]string or a pointer to a slice of structs", a)
+	}
+	records, err := reader.ReadAll()
+	if err != nil {
+		return err
+	}
+	sliceType := target.Elem().Type()
+	slice := reflect.MakeSlice(sliceType, 0, len(records))
+	if len(records) == 0 {
+		target.Elem().Set(slice)
+		return nil
+	}
+	header := make(map[string]int, len(records[0]))
+	for i, name := range records[0] {
+		header[name] = i
+	}
+	var errs []FieldError
+	for row, record := range records[1:] {
+		item := reflect.New(structType)
+		source := bindingSource{name: "csv", tagKey: "csv", lookup: func(key string) ([]string, bool) {
+			index, found := header[key]
+			if !found {
+				return nil, false
+			}
+			return []string{record[index]}, true
+		}}
+		var rowErrs []FieldError
+		bindStruct(item.Elem(), []bindingSource{source}, "", &rowErrs)
+		for _, rowErr := range rowErrs {
+			rowErr.Field = fmt.Sprintf("[%d].%s", row, rowErr.Field)
+			errs = append(errs, rowErr)
+		}
+		if sliceType.Elem().Kind() == reflect.Ptr {
+			slice = reflect.Append(slice, item)
+		} else {
+			slice = reflect.Append(slice, item.Elem())
+		}
+	}
+	if len(errs) != 0 {
+		return BindingError{Errors: errs}
+	}
+	target.Elem().Set(slice)
+	return nil
+}
@@ -0,0 +1,56 @@
+package stgin
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type csvRow struct {
+	Name    string    `csv:"name"`
+	Price   float64   `csv:"price"`
+	Stock   *int      `csv:"stock"`
+	Updated time.Time `csv:"updated"`
+	Secret  string    `csv:"-"`
+}
+
+func TestCSVCodec(t *testing.T) {
+	stock := 3
+	updated := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	rows := []csvRow{{Name: "pen, blue", Price: 1.5, Stock: &stock, Updated: updated, Secret: "x"}, {Name: "cup", Price: 2}}
+	encoded, err := CSVCodec{}.Marshal(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "name,price,stock,updated\n\"pen, blue\",1.5,3,2022-05-01T10:00:00Z\ncup,2,,0001-01-01T00:00:00Z\n"
+	if string(encoded) != expected {
+		t.Fatalf("unexpected csv:\n%s", encoded)
+	}
+	var decoded []*csvRow
+	if err = (CSVCodec{}).Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	rows[0].Secret = ""
+	if len(decoded) != 2 || !reflect.DeepEqual(*decoded[0], rows[0]) || !reflect.DeepEqual(*decoded[1], rows[1]) {
+		t.Fatalf("expected %+v, got %+v %+v", rows, decoded[0], decoded[1])
+	}
+
+	semicolons := CSVCodec{Comma: ';'}
+	records := [][]string{{"a", "b;c"}, {"1", "2"}}
+	if encoded, _ = semicolons.Marshal(records); string(encoded) != "a;\"b;c\"\n1;2\n" {
+		t.Fatalf("unexpected csv:\n%s", encoded)
+	}
+	var decodedRecords [][]string
+	if err = semicolons.Unmarshal(encoded, &decodedRecords); err != nil || !reflect.DeepEqual(decodedRecords, records) {
+		t.Fatalf("expected %v, got %v (%v)", records, decodedRecords, err)
+	}
+
+	err = (CSVCodec{}).Unmarshal([]byte("name,price\npen,1\ncup,free\n"), &decoded)
+	if err == nil || !strings.Contains(err.Error(), "[1].price") {
+		t.Fatalf("expected the invalid cell to be reported, got %v", err)
+	}
+	if _, err = (CSVCodec{}).Marshal(map[string]string{}); err == nil {
+		t.Fatal("maps should not be encoded as csv")
+	}
+}
@@ -1,6 +1,9 @@
 package stgin
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type ParseError struct {
 	tpe     string
@@ -18,3 +21,63 @@ type MalformedRequestContext struct {
 func (mrc MalformedRequestContext) Error() string {
 	return fmt.Sprintf("could not read from request, %v", mrc.details)
 }
+
+// QueryError is returned when the query parameters of a request do not satisfy the query declarations of a route.
+type QueryError struct {
+	query   string
+	details string
+}
+
+// Query returns the name of the query parameter which was not satisfied.
+func (qe QueryError) Query() string { return qe.query }
+
+func (qe QueryError) Error() string {
+	return fmt.Sprintf("invalid query parameter '%v', %v", qe.query, qe.details)
+}
+
+// FieldError describes a single field which could not be bound from the request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Source  string `json:"source,omitempty"`
+	Message string `json:"message"`
+}
+
+// BindingError aggregates all the field errors that happened while binding request values into an object.
+type BindingError struct {
+	Errors []FieldError
+}
+
+func (be BindingError) Error() string {
+	descriptions := make([]string, len(be.Errors))
+	for i, fieldErr := range be.Errors {
+		descriptions[i] = fmt.Sprintf("%v: %v", fieldErr.Field, fieldErr.Message)
+	}
+	return fmt.Sprintf("could not bind request values, %v", strings.Join(descriptions, "; "))
+}
+
+// ValidationError aggregates all the field errors that happened while validating an object using its `validate` tags.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (ve ValidationError) Error() string {
+	descriptions := make([]string, len(ve.Errors))
+	for i, fieldErr := range ve.Errors {
+		descriptions[i] = fmt.Sprintf("%v %v", fieldErr.Field, fieldErr.Message)
+	}
+	return fmt.Sprintf("validation failed, %v", strings.Join(descriptions, "; "))
+}
+
+// WebSocketCloseError is returned when a websocket connection is closed, holding the close code and reason
+// sent by the peer (or by stgin, in case the peer violated the protocol).
+type WebSocketCloseError struct {
+	Code   int
+	Reason string
+}
+
+func (wce WebSocketCloseError) Error() string {
+	if wce.Reason == "" {
+		return fmt.Sprintf("websocket closed with code %d", wce.Code)
+	}
+	return fmt.Sprintf("websocket closed with code %d, %v", wce.Code, wce.Reason)
+}
@@ -0,0 +1,163 @@
+package stgin
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ETagConfig is the configuration of the ETag layer.
+type ETagConfig struct {
+	// Weak generates weak ETags (W/"..."), which only promise semantically equivalent representations.
+	Weak bool
+	// Version returns the current version of the requested resource (i.e., a revision number or the time it was updated),
+	// or an empty string if the resource does not exist. It's used as the ETag instead of hashing the response,
+	// which lets GET requests be answered with 304 without calling the API, and lets PUT, PATCH and DELETE requests
+	// be checked against their If-Match and If-None-Match headers before they're applied.
+	Version func(request RequestContext) string
+}
+
+// ETag generates ETags for the successful responses of the api, by hashing the bytes of their entities,
+// and answers the requests whose If-None-Match header matches the ETag with 304 not modified.
+func ETag(api API) API {
+	return ETagWithConfig(api, ETagConfig{})
+}
+
+// ETagWithConfig is the same as ETag, using the given configuration. Responses which already have an ETag header
+// keep it, and streamed entities are not hashed, so they only get an ETag if a version is provided.
+func ETagWithConfig(api API, config ETagConfig) API {
+	if api == nil {
+		printStacktrace("")
+		panic("nil api provided as the etag layer's api")
+	}
+	return func(request RequestContext) Status {
+		var current string
+		if config.Version != nil {
+			if version := config.Version(request); version != "" {
+				current = formatETag(version, config.Weak)
+			}
+		}
+		safe := request.Method == http.MethodGet || request.Method == http.MethodHead
+		if config.Version != nil && !safe {
+			if !preconditionsHold(request.Headers, current) {
+				return preconditionFailedResponse(request, current)
+			}
+		} else if current != "" && etagMatches(request.Headers.Get("If-None-Match"), current, false) {
+			return notModifiedResponse(http.Header{}, current)
+		}
+
+		status := api(request)
+		if !safe || status.StatusCode < 200 || status.StatusCode >= 300 {
+			return status
+		}
+		etag := status.Headers.Get("ETag")
+		if etag == "" {
+			etag = current
+		}
+		_, isServed := status.Entity.(servedEntity)
+		if etag == "" && !isServed && status.Entity != nil {
+			bytes, contentType, err := marshall(status.Entity, codecsOf(request.Underlying))
+			if err != nil {
+				// left to be reported when the status is written
+				return status
+			}
+			// the entity is not encoded again while being written
+			status.Entity = bytesEntity{contentType: contentType, bytes: bytes}
+			etag = hashETag(bytes, config.Weak)
+		}
+		if etag == "" {
+			return status
+		}
+		if !isServed && etagMatches(request.Headers.Get("If-None-Match"), etag, false) {
+			return notModifiedResponse(status.Headers, etag)
+		}
+		// served entities (like files) evaluate the conditional headers themselves once they know the ETag
+		return status.WithHeader("ETag", etag)
+	}
+}
+
+// formatETag quotes the version as an entity tag, versions which are already entity tags are kept.
+func formatETag(version string, weak bool) string {
+	if strings.HasPrefix(version, `"`) || strings.HasPrefix(version, `W/"`) {
+		return version
+	}
+	etag := strconv.Quote(version)
+	if weak {
+		return "W/" + etag
+	}
+	return etag
+}
+
+func hashETag(bytes []byte, weak bool) string {
+	sum := sha256.Sum256(bytes)
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:18]) + `"`
+	if weak {
+		return "W/" + etag
+	}
+	return etag
+}
+
+// etagMatches reports whether any of the entity tags in the header (or "*") match the etag.
+// Strong comparison is used for If-Match, in which weak tags never match, and weak comparison for If-None-Match.
+func etagMatches(header string, etag string, strong bool) bool {
+	if header == "" || etag == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	if strong && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	opaque := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strong && strings.HasPrefix(candidate, "W/") {
+			continue
+		}
+		if strings.TrimPrefix(candidate, "W/") == opaque {
+			return true
+		}
+	}
+	return false
+}
+
+// preconditionsHold evaluates If-Match and If-None-Match for state changing requests, against the current etag of
+// the resource, which is empty for resources which do not exist.
+func preconditionsHold(headers http.Header, current string) bool {
+	if ifMatch := headers.Get("If-Match"); ifMatch != "" && !etagMatches(ifMatch, current, true) {
+		return false
+	}
+	if ifNoneMatch := headers.Get("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, current, false) {
+		return false
+	}
+	return true
+}
+
+func preconditionFailedResponse(request RequestContext, current string) Status {
+	status := failureResponse(request, http.StatusPreconditionFailed, "precondition failed")
+	if current != "" {
+		return status.WithHeader("ETag", current)
+	}
+	return status
+}
+
+// notModifiedHeaders are the headers kept in 304 responses (RFC 9110, section 15.4.5).
+var notModifiedHeaders = []string{"Cache-Control", "Content-Location", "Date", "Expires", "Vary"}
+
+func notModifiedResponse(original http.Header, etag string) Status {
+	headers := http.Header{}
+	for _, key := range notModifiedHeaders {
+		if values := original.Values(key); len(values) != 0 {
+			headers[key] = append([]string{}, values...)
+		}
+	}
+	headers.Set("ETag", etag)
+	return Status{
+		StatusCode: http.StatusNotModified,
+		Entity:     Empty(),
+		Headers:    headers,
+	}
+}
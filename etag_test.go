@@ -0,0 +1,135 @@
+package stgin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestETagMatches(t *testing.T) {
+	cases := []struct {
+		header string
+		etag   string
+		strong bool
+		match  bool
+	}{
+		{`"a"`, `"a"`, true, true},
+		{`"b", "a"`, `"a"`, true, true},
+		{`W/"a"`, `"a"`, true, false},
+		{`W/"a"`, `"a"`, false, true},
+		{`"a"`, `W/"a"`, false, true},
+		{`"a"`, `W/"a"`, true, false},
+		{`*`, `"a"`, true, true},
+		{`*`, ``, true, false},
+		{``, `"a"`, false, false},
+		{`"b"`, `"a"`, false, false},
+	}
+	for _, c := range cases {
+		if matches := etagMatches(c.header, c.etag, c.strong); matches != c.match {
+			t.Errorf("expected %v for %q against %q (strong: %v)", c.match, c.header, c.etag, c.strong)
+		}
+	}
+}
+
+func TestETag(t *testing.T) {
+	calls := 0
+	version := 1
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "report.txt"), []byte("report"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	controller := NewController("ETags", "")
+	controller.AddRoutes(
+		GET("/hashed", ETag(func(RequestContext) Status {
+			calls++
+			return Ok(Json(map[string]int{"id": 1}))
+		})),
+		GET("/weak", ETagWithConfig(func(RequestContext) Status {
+			return Ok(Text("weak")).WithCacheControl("max-age=60")
+		}, ETagConfig{Weak: true})),
+		GET("/failing", ETag(func(RequestContext) Status { return NotFound(Text("not found")) })),
+		GET("/file", ETag(func(RequestContext) Status {
+			return Ok(Attachment(filepath.Join(dir, "report.txt"), "report.txt")).WithHeader("ETag", `"v7"`)
+		})),
+	)
+	versioned := ETagConfig{Version: func(RequestContext) string { return strconv.Itoa(version) }}
+	document := func(RequestContext) Status {
+		calls++
+		return Ok(Text("document"))
+	}
+	controller.AddRoutes(
+		GET("/document", ETagWithConfig(document, versioned)),
+		PUT("/document", ETagWithConfig(func(request RequestContext) Status {
+			version++
+			return document(request)
+		}, versioned)),
+	)
+	server := &Server{Controllers: []*Controller{controller}, notFoundAction: notFoundDefaultAction}
+	serve := func(method string, path string, headers map[string]string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, nil)
+		for key, value := range headers {
+			request.Header.Set(key, value)
+		}
+		recorder := httptest.NewRecorder()
+		server.HttpHandler().ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	response := serve(http.MethodGet, "/hashed", nil)
+	etag := response.Header().Get("ETag")
+	if response.Code != http.StatusOK || len(etag) < 3 || etag[0] != '"' || response.Body.String() != `{"id":1}` {
+		t.Fatalf("expected a strong etag, got %d %q", response.Code, etag)
+	}
+	response = serve(http.MethodGet, "/hashed", map[string]string{"If-None-Match": `"other", ` + etag})
+	if response.Code != http.StatusNotModified || response.Body.Len() != 0 || response.Header().Get("ETag") != etag {
+		t.Fatalf("expected not modified, got %d: %s", response.Code, response.Body.String())
+	}
+
+	response = serve(http.MethodGet, "/weak", nil)
+	etag = response.Header().Get("ETag")
+	if etag[:3] != `W/"` {
+		t.Fatalf("expected a weak etag, got %q", etag)
+	}
+	response = serve(http.MethodGet, "/weak", map[string]string{"If-None-Match": etag})
+	if response.Code != http.StatusNotModified || response.Header().Get("Cache-Control") != "max-age=60" {
+		t.Fatalf("expected not modified with the cache headers, got %d %v", response.Code, response.Header())
+	}
+
+	if response = serve(http.MethodGet, "/failing", nil); response.Header().Get("ETag") != "" {
+		t.Fatalf("unsuccessful responses should not have etags")
+	}
+
+	response = serve(http.MethodGet, "/file", map[string]string{"If-None-Match": `"v7"`})
+	if response.Code != http.StatusNotModified {
+		t.Fatalf("served entity did not evaluate the provided etag, got %d", response.Code)
+	}
+
+	calls = 0
+	response = serve(http.MethodGet, "/document", map[string]string{"If-None-Match": `"1"`})
+	if response.Code != http.StatusNotModified || calls != 0 {
+		t.Fatalf("expected not modified without calling the api, got %d after %d calls", response.Code, calls)
+	}
+	response = serve(http.MethodPut, "/document", map[string]string{"If-Match": `"2"`})
+	if response.Code != http.StatusPreconditionFailed || calls != 0 || response.Header().Get("ETag") != `"1"` {
+		t.Fatalf("expected precondition failed, got %d after %d calls", response.Code, calls)
+	}
+	response = serve(http.MethodPut, "/document", map[string]string{"If-None-Match": `*`})
+	if response.Code != http.StatusPreconditionFailed || calls != 0 {
+		t.Fatalf("expected precondition failed for an existing resource, got %d", response.Code)
+	}
+	response = serve(http.MethodPut, "/document", map[string]string{"If-Match": `"1"`})
+	if response.Code != http.StatusOK || calls != 1 || version != 2 {
+		t.Fatalf("expected the update to be applied, got %d after %d calls", response.Code, calls)
+	}
+	response = serve(http.MethodPut, "/document", map[string]string{"If-Match": `"1"`})
+	if response.Code != http.StatusPreconditionFailed || version != 2 {
+		t.Fatalf("expected a stale update to fail, got %d", response.Code)
+	}
+	response = serve(http.MethodGet, "/document", map[string]string{"If-None-Match": `"1"`})
+	if response.Code != http.StatusOK || response.Header().Get("ETag") != `"2"` || response.Body.String() != "document" {
+		t.Fatalf("expected the new version, got %d %q", response.Code, response.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,131 @@
+package stgin
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+)
+
+const formUrlEncoded = "application/x-www-form-urlencoded"
+
+// FormCodec is the built-in application/x-www-form-urlencoded codec. Structs are encoded and decoded using the `form`
+// tags of their fields (or their names), the same way as Bind, and maps of strings or string slices are supported as well.
+type FormCodec struct{}
+
+func (codec FormCodec) Marshal(a any) ([]byte, error) {
+	values := url.Values{}
+	value := reflect.ValueOf(a)
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	switch {
+	case value.Kind() == reflect.Map && value.Type().Key().Kind() == reflect.String:
+		iterator := value.MapRange()
+		for iterator.Next() {
+			if err := appendFormValues(values, iterator.Key().String(), iterator.Value()); err != nil {
+				return nil, err
+			}
+		}
+	case value.Kind() == reflect.Struct:
+		if err := appendFormFields(values, value, ""); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("cannot encode %T as a form, expected a struct or a map with string keys", a)
+	}
+	return []byte(values.Encode()), nil
+}
+
+// appendFormFields flattens the struct into the values, nested structs are prefixed by their name and a dot.
+func appendFormFields(values url.Values, value reflect.Value, prefix string) error {
+	tpe := value.Type()
+	for i := 0; i < tpe.NumField(); i++ {
+		field := tpe.Field(i)
+		if !field.IsExported() && !(field.Anonymous && field.Type.Kind() == reflect.Struct) {
+			continue
+		}
+		tag := parseBindingTag(field, "form")
+		if tag.skip {
+			continue
+		}
+		fieldValue := value.Field(i)
+		if fieldValue.Kind() == reflect.Ptr {
+			if fieldValue.IsNil() {
+				continue
+			}
+			fieldValue = fieldValue.Elem()
+		}
+		if isNestedStruct(fieldValue.Type()) {
+			nestedPrefix := prefix + tag.name + "."
+			if _, tagged := field.Tag.Lookup("form"); field.Anonymous && !tagged {
+				nestedPrefix = prefix
+			}
+			if err := appendFormFields(values, fieldValue, nestedPrefix); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := appendFormValues(values, prefix+tag.name, fieldValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendFormValues adds the value, or all the items of slices, as the values of the key.
+func appendFormValues(values url.Values, key string, value reflect.Value) error {
+	for value.Kind() == reflect.Interface && !value.IsNil() {
+		value = value.Elem()
+	}
+	if (value.Kind() == reflect.Slice || value.Kind() == reflect.Array) && value.Type().Elem().Kind() != reflect.Uint8 {
+		if _, isMarshaler := textMarshalerOf(value); !isMarshaler {
+			for i := 0; i < value.Len(); i++ {
+				if err := appendFormValues(values, key, value.Index(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	formatted, err := formatScalar(value)
+	if err != nil {
+		return fmt.Errorf("field '%s': %w", key, err)
+	}
+	values.Add(key, formatted)
+	return nil
+}
+
+func (codec FormCodec) Unmarshal(data []byte, a any) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+	switch target := a.(type) {
+	case *url.Values:
+		*target = values
+		return nil
+	case *map[string][]string:
+		*target = values
+		return nil
+	case *map[string]string:
+		*target = make(map[string]string, len(values))
+		for key := range values {
+			(*target)[key] = values.Get(key)
+		}
+		return nil
+	}
+	target := reflect.ValueOf(a)
+	if target.Kind() != reflect.Ptr || target.IsNil() || target.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot decode a form into %T, expected a pointer to a struct or a map", a)
+	}
+	source := bindingSource{name: "form", tagKey: "form", lookup: func(key string) ([]string, bool) {
+		found, isFound := values[key]
+		return found, isFound
+	}}
+	var errs []FieldError
+	bindStruct(target.Elem(), []bindingSource{source}, "", &errs)
+	if len(errs) != 0 {
+		return BindingError{Errors: errs}
+	}
+	return nil
+}
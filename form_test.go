@@ -0,0 +1,55 @@
+package stgin
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type formAddress struct {
+	City string `form:"city"`
+}
+
+type formSignup struct {
+	Username string      `form:"username"`
+	Age      int         `form:"age"`
+	Tags     []string    `form:"tag"`
+	Address  formAddress `form:"address"`
+	Referrer *string     `form:"referrer"`
+	Secret   string      `form:"-"`
+}
+
+func TestFormCodec(t *testing.T) {
+	signup := formSignup{Username: "john doe", Age: 22, Tags: []string{"a", "b"}, Address: formAddress{City: "Tehran"}, Secret: "x"}
+	encoded, err := FormCodec{}.Marshal(&signup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != "address.city=Tehran&age=22&tag=a&tag=b&username=john+doe" {
+		t.Fatalf("unexpected form: %s", encoded)
+	}
+	var decoded formSignup
+	if err = (FormCodec{}).Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	signup.Secret = ""
+	if !reflect.DeepEqual(decoded, signup) {
+		t.Fatalf("expected %+v, got %+v", signup, decoded)
+	}
+
+	if encoded, _ = (FormCodec{}).Marshal(map[string]any{"q": "x y", "ids": []int{1, 2}}); string(encoded) != "ids=1&ids=2&q=x+y" {
+		t.Fatalf("unexpected form: %s", encoded)
+	}
+	var values url.Values
+	if err = (FormCodec{}).Unmarshal([]byte("a=1&a=2&b=3"), &values); err != nil || len(values["a"]) != 2 {
+		t.Fatalf("form was not decoded into values, got %v (%v)", values, err)
+	}
+	var single map[string]string
+	if err = (FormCodec{}).Unmarshal([]byte("a=1&a=2&b=3"), &single); err != nil || single["a"] != "1" || single["b"] != "3" {
+		t.Fatalf("form was not decoded into a map, got %v (%v)", single, err)
+	}
+	if err = (FormCodec{}).Unmarshal([]byte("age=old"), &decoded); err == nil || !strings.Contains(err.Error(), "age") {
+		t.Fatalf("expected the invalid field to be reported, got %v", err)
+	}
+}
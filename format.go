@@ -0,0 +1,522 @@
+package stgin
+
+import (
+	"encoding"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The built-in formats which are not supported by the standard library (YAML and MessagePack) are encoded from,
+// and decoded into trees of nil, bool, int64, uint64, float64, string, []byte, time.Time, []any and treeMap values.
+
+// treeEntry is an entry of a map inside a tree, maps keep their order so that struct fields are encoded in order.
+type treeEntry struct {
+	key   any
+	value any
+}
+
+type treeMap []treeEntry
+
+// maxTreeDepth limits the nesting of decoded trees, so that malicious payloads cannot exhaust the stack.
+const maxTreeDepth = 10000
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// treeField is an exported struct field, named by its tag (or its name), embedded struct fields are flattened.
+type treeField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+func treeFields(tpe reflect.Type, tagKey string) []treeField {
+	var fields, embedded []treeField
+	for i := 0; i < tpe.NumField(); i++ {
+		field := tpe.Field(i)
+		raw, tagged := field.Tag.Lookup(tagKey)
+		if raw == "-" {
+			continue
+		}
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && !tagged && fieldType.Kind() == reflect.Struct {
+			for _, nested := range treeFields(fieldType, tagKey) {
+				nested.index = append([]int{i}, nested.index...)
+				embedded = append(embedded, nested)
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		parts := strings.Split(raw, ",")
+		treeField := treeField{name: parts[0], index: []int{i}}
+		if treeField.name == "" {
+			treeField.name = field.Name
+		}
+		for _, option := range parts[1:] {
+			if option == "omitempty" {
+				treeField.omitEmpty = true
+			}
+		}
+		fields = append(fields, treeField)
+	}
+	// fields of the struct itself hide the fields of embedded structs with the same name
+	for _, field := range embedded {
+		hidden := false
+		for _, existing := range fields {
+			if existing.name == field.name {
+				hidden = true
+				break
+			}
+		}
+		if !hidden {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// fieldByIndex returns the nested field, allocating nil embedded pointers if allocate is true.
+func fieldByIndex(value reflect.Value, index []int, allocate bool) (reflect.Value, bool) {
+	for i, fieldIndex := range index {
+		if i > 0 && value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				if !allocate {
+					return reflect.Value{}, false
+				}
+				value.Set(reflect.New(value.Type().Elem()))
+			}
+			value = value.Elem()
+		}
+		value = value.Field(fieldIndex)
+	}
+	return value, true
+}
+
+func textMarshalerOf(value reflect.Value) (encoding.TextMarshaler, bool) {
+	if value.Type().Implements(textMarshalerType) {
+		return value.Interface().(encoding.TextMarshaler), true
+	}
+	if reflect.PtrTo(value.Type()).Implements(textMarshalerType) {
+		pointer := reflect.New(value.Type())
+		pointer.Elem().Set(value)
+		return pointer.Interface().(encoding.TextMarshaler), true
+	}
+	return nil, false
+}
+
+// toTree converts the value into a tree, naming struct fields using the given tag.
+// Times are formatted as RFC3339, durations like 1h30m, and text marshalers using their text.
+func toTree(value reflect.Value, tagKey string) (any, error) {
+	if !value.IsValid() {
+		return nil, nil
+	}
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil, nil
+		}
+		value = value.Elem()
+	}
+	switch value.Type() {
+	case timeType:
+		return value.Interface().(time.Time).Format(time.RFC3339Nano), nil
+	case durationType:
+		return time.Duration(value.Int()).String(), nil
+	}
+	if marshaler, isMarshaler := textMarshalerOf(value); isMarshaler {
+		text, err := marshaler.MarshalText()
+		return string(text), err
+	}
+	switch value.Kind() {
+	case reflect.Bool:
+		return value.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return value.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), nil
+	case reflect.String:
+		return value.String(), nil
+	case reflect.Slice, reflect.Array:
+		if value.Kind() == reflect.Slice {
+			if value.IsNil() {
+				return nil, nil
+			}
+			if value.Type().Elem().Kind() == reflect.Uint8 {
+				return value.Bytes(), nil
+			}
+		}
+		items := make([]any, value.Len())
+		for i := range items {
+			item, err := toTree(value.Index(i), tagKey)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	case reflect.Map:
+		if value.IsNil() {
+			return nil, nil
+		}
+		entries := make(treeMap, 0, value.Len())
+		iterator := value.MapRange()
+		for iterator.Next() {
+			key, err := toTree(iterator.Key(), tagKey)
+			if err != nil {
+				return nil, err
+			}
+			item, err := toTree(iterator.Value(), tagKey)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, treeEntry{key: key, value: item})
+		}
+		// maps are not ordered, so they're sorted to be encoded the same way every time
+		sort.Slice(entries, func(i, j int) bool { return fmt.Sprint(entries[i].key) < fmt.Sprint(entries[j].key) })
+		return entries, nil
+	case reflect.Struct:
+		fields := treeFields(value.Type(), tagKey)
+		entries := make(treeMap, 0, len(fields))
+		for _, field := range fields {
+			fieldValue, found := fieldByIndex(value, field.index, false)
+			if !found || (field.omitEmpty && isEmptyValue(fieldValue)) {
+				continue
+			}
+			item, err := toTree(fieldValue, tagKey)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, treeEntry{key: field.name, value: item})
+		}
+		return entries, nil
+	default:
+		return nil, fmt.Errorf("cannot encode values of type %s", value.Type().String())
+	}
+}
+
+// naturalTree converts the maps of the tree into map[string]any, which is how trees are decoded into interfaces.
+func naturalTree(value any) any {
+	switch node := value.(type) {
+	case treeMap:
+		result := make(map[string]any, len(node))
+		for _, entry := range node {
+			key, isString := entry.key.(string)
+			if !isString {
+				key = fmt.Sprint(entry.key)
+			}
+			result[key] = naturalTree(entry.value)
+		}
+		return result
+	case []any:
+		for i, item := range node {
+			node[i] = naturalTree(item)
+		}
+		return node
+	default:
+		return value
+	}
+}
+
+func treeTypeName(value any) string {
+	switch value.(type) {
+	case treeMap:
+		return "map"
+	case []any:
+		return "sequence"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int64, uint64, float64:
+		return "number"
+	case []byte:
+		return "binary"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// treeError is the error of decoding a tree into a value, which reports the path of the invalid value.
+func treeError(path string, format string, args ...any) error {
+	if path == "" {
+		return fmt.Errorf(format, args...)
+	}
+	return fmt.Errorf("invalid value of '%s', %s", path, fmt.Sprintf(format, args...))
+}
+
+func treeMismatch(path string, expected string, value any) error {
+	return treeError(path, "expected %s, got %s", expected, treeTypeName(value))
+}
+
+func treeIndexPath(path string, index int) string {
+	return fmt.Sprintf("%s[%d]", path, index)
+}
+
+// assignTree decodes the tree into the target, finding struct fields by the given tag.
+// Just like encoding/json, keys which match no fields are ignored.
+func assignTree(target reflect.Value, value any, tagKey string, path string) error {
+	tpe := target.Type()
+	if value == nil {
+		target.Set(reflect.Zero(tpe))
+		return nil
+	}
+	if tpe.Kind() == reflect.Ptr {
+		elem := target
+		if target.IsNil() {
+			elem = reflect.New(tpe.Elem())
+		}
+		if err := assignTree(elem.Elem(), value, tagKey, path); err != nil {
+			return err
+		}
+		target.Set(elem)
+		return nil
+	}
+	if tpe.Kind() == reflect.Interface {
+		if tpe.NumMethod() != 0 {
+			return treeError(path, "cannot decode into non-empty interface %s", tpe.String())
+		}
+		target.Set(reflect.ValueOf(naturalTree(value)))
+		return nil
+	}
+	if decoded, isTime := value.(time.Time); isTime && tpe == timeType {
+		target.Set(reflect.ValueOf(decoded))
+		return nil
+	}
+	if raw, isString := value.(string); isString {
+		if tpe == timeType || tpe == durationType || (reflect.PtrTo(tpe).Implements(textUnmarshalerType) && tpe != timeType) {
+			if err := setFromString(target, raw); err != nil {
+				return treeError(path, "%s", err.Error())
+			}
+			return nil
+		}
+	}
+	switch tpe.Kind() {
+	case reflect.Bool:
+		if decoded, isBool := value.(bool); isBool {
+			target.SetBool(decoded)
+			return nil
+		}
+		return assignScalarString(target, value, path, "a boolean")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var decoded int64
+		switch number := value.(type) {
+		case int64:
+			decoded = number
+		case uint64:
+			if number > math.MaxInt64 {
+				return treeError(path, "%d overflows %s", number, tpe.String())
+			}
+			decoded = int64(number)
+		case float64:
+			if number != math.Trunc(number) || number < math.MinInt64 || number >= math.MaxInt64 {
+				return treeError(path, "expected an integer, got %v", number)
+			}
+			decoded = int64(number)
+		default:
+			return assignScalarString(target, value, path, "an integer")
+		}
+		if target.OverflowInt(decoded) {
+			return treeError(path, "%d overflows %s", decoded, tpe.String())
+		}
+		target.SetInt(decoded)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var decoded uint64
+		switch number := value.(type) {
+		case uint64:
+			decoded = number
+		case int64:
+			if number < 0 {
+				return treeError(path, "expected an unsigned integer, got %d", number)
+			}
+			decoded = uint64(number)
+		case float64:
+			if number != math.Trunc(number) || number < 0 || number >= math.MaxUint64 {
+				return treeError(path, "expected an unsigned integer, got %v", number)
+			}
+			decoded = uint64(number)
+		default:
+			return assignScalarString(target, value, path, "an unsigned integer")
+		}
+		if target.OverflowUint(decoded) {
+			return treeError(path, "%d overflows %s", decoded, tpe.String())
+		}
+		target.SetUint(decoded)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		switch number := value.(type) {
+		case float64:
+			target.SetFloat(number)
+		case int64:
+			target.SetFloat(float64(number))
+		case uint64:
+			target.SetFloat(float64(number))
+		default:
+			return assignScalarString(target, value, path, "a number")
+		}
+		return nil
+	case reflect.String:
+		switch scalar := value.(type) {
+		case string:
+			target.SetString(scalar)
+		case []byte:
+			target.SetString(string(scalar))
+		case bool, int64, uint64, float64:
+			target.SetString(fmt.Sprint(scalar))
+		default:
+			return treeMismatch(path, "a string", value)
+		}
+		return nil
+	case reflect.Slice:
+		if tpe.Elem().Kind() == reflect.Uint8 {
+			switch data := value.(type) {
+			case []byte:
+				target.SetBytes(append([]byte{}, data...))
+				return nil
+			case string:
+				// just like encoding/json, byte slices are encoded as base64 inside text formats
+				decoded, err := base64.StdEncoding.DecodeString(data)
+				if err != nil {
+					return treeError(path, "expected base64 encoded bytes")
+				}
+				target.SetBytes(decoded)
+				return nil
+			}
+		}
+		items, isSequence := value.([]any)
+		if !isSequence {
+			return treeMismatch(path, "a sequence", value)
+		}
+		slice := reflect.MakeSlice(tpe, len(items), len(items))
+		for i, item := range items {
+			if err := assignTree(slice.Index(i), item, tagKey, treeIndexPath(path, i)); err != nil {
+				return err
+			}
+		}
+		target.Set(slice)
+		return nil
+	case reflect.Array:
+		items, isSequence := value.([]any)
+		if !isSequence {
+			return treeMismatch(path, "a sequence", value)
+		}
+		if len(items) > target.Len() {
+			return treeError(path, "expected at most %d items, got %d", target.Len(), len(items))
+		}
+		for i := 0; i < target.Len(); i++ {
+			var item any
+			if i < len(items) {
+				item = items[i]
+			}
+			if err := assignTree(target.Index(i), item, tagKey, treeIndexPath(path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		entries, isMap := value.(treeMap)
+		if !isMap {
+			return treeMismatch(path, "a map", value)
+		}
+		if target.IsNil() {
+			target.Set(reflect.MakeMapWithSize(tpe, len(entries)))
+		}
+		for _, entry := range entries {
+			key := reflect.New(tpe.Key()).Elem()
+			entryPath := joinFieldPath(path, fmt.Sprint(entry.key))
+			if err := assignTree(key, entry.key, tagKey, entryPath); err != nil {
+				return err
+			}
+			item := reflect.New(tpe.Elem()).Elem()
+			if err := assignTree(item, entry.value, tagKey, entryPath); err != nil {
+				return err
+			}
+			target.SetMapIndex(key, item)
+		}
+		return nil
+	case reflect.Struct:
+		entries, isMap := value.(treeMap)
+		if !isMap {
+			return treeMismatch(path, "a map", value)
+		}
+		fields := treeFields(tpe, tagKey)
+		for _, entry := range entries {
+			name := fmt.Sprint(entry.key)
+			field, found := findTreeField(fields, name)
+			if !found {
+				continue
+			}
+			fieldValue, _ := fieldByIndex(target, field.index, true)
+			if err := assignTree(fieldValue, entry.value, tagKey, joinFieldPath(path, name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return treeError(path, "cannot decode into values of type %s", tpe.String())
+	}
+}
+
+// findTreeField finds the field with the exact name, or otherwise, case-insensitively just like encoding/json.
+func findTreeField(fields []treeField, name string) (treeField, bool) {
+	for _, field := range fields {
+		if field.name == name {
+			return field, true
+		}
+	}
+	for _, field := range fields {
+		if strings.EqualFold(field.name, name) {
+			return field, true
+		}
+	}
+	return treeField{}, false
+}
+
+// assignScalarString decodes strings into scalar types (i.e., map keys or quoted numbers).
+func assignScalarString(target reflect.Value, value any, path string, expected string) error {
+	raw, isString := value.(string)
+	if !isString {
+		return treeMismatch(path, expected, value)
+	}
+	if err := setFromString(target, raw); err != nil {
+		return treeError(path, "%s", err.Error())
+	}
+	return nil
+}
+
+// formatScalar formats values of flat formats (like CSV cells and form values), the same way setFromString parses them.
+func formatScalar(value reflect.Value) (string, error) {
+	tree, err := toTree(value, "")
+	if err != nil {
+		return "", err
+	}
+	switch scalar := tree.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return scalar, nil
+	case []byte:
+		return string(scalar), nil
+	case bool:
+		return strconv.FormatBool(scalar), nil
+	case int64:
+		return strconv.FormatInt(scalar, 10), nil
+	case uint64:
+		return strconv.FormatUint(scalar, 10), nil
+	case float64:
+		return strconv.FormatFloat(scalar, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("cannot format values of type %s as text", value.Type().String())
+	}
+}
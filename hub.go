@@ -0,0 +1,236 @@
+package stgin
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultHubBufferSize is the number of messages buffered for each subscriber, if not configured.
+const defaultHubBufferSize = 16
+
+// SlowConsumerPolicy decides what happens to subscribers which do not receive messages as fast as they're published.
+type SlowConsumerPolicy int
+
+const (
+	// DropMessages drops the messages which do not fit in the subscriber's buffer.
+	DropMessages SlowConsumerPolicy = iota
+	// DisconnectSlowConsumers closes the subscriptions whose buffer is full, with ErrSlowConsumer.
+	DisconnectSlowConsumers
+)
+
+var (
+	// ErrSlowConsumer is the reason of the subscriptions closed because of the DisconnectSlowConsumers policy.
+	ErrSlowConsumer = errors.New("subscriber is too slow to receive the published messages")
+	// ErrHubClosed is the reason of the subscriptions closed because the hub is closed (i.e., the server is shut down).
+	ErrHubClosed = errors.New("hub is closed")
+)
+
+// HubConfig holds the specifications of a Hub.
+type HubConfig struct {
+	// BufferSize is the number of messages buffered for each subscriber. Defaults to 16.
+	BufferSize int
+	// SlowConsumerPolicy decides what happens when the buffer of a subscriber is full. Defaults to DropMessages.
+	SlowConsumerPolicy SlowConsumerPolicy
+}
+
+// HubMessage is a message published into a topic of a hub.
+type HubMessage struct {
+	Topic string
+	Data  any
+}
+
+// HubMetrics is a snapshot of a hub's statistics.
+type HubMetrics struct {
+	// Subscribers is the number of the open subscriptions.
+	Subscribers int
+	// Topics holds the number of subscribers of each topic which has any.
+	Topics       map[string]int
+	Published    uint64
+	Delivered    uint64
+	Dropped      uint64
+	Disconnected uint64
+}
+
+// Hub is a publish/subscribe broker, which fans out the published messages to the subscribers of their topics,
+// (like event streams or websocket connections). Hubs created using server.NewHub are closed when the server shuts down.
+// All the methods are safe to be called concurrently.
+type Hub struct {
+	mutex  sync.RWMutex
+	config HubConfig
+	topics map[string]map[*Subscription]struct{}
+	closed bool
+
+	published    uint64
+	delivered    uint64
+	dropped      uint64
+	disconnected uint64
+}
+
+// NewHub returns a new hub, which should be closed by the caller; use server.NewHub to bind it to the server's lifecycle.
+func NewHub(config HubConfig) *Hub {
+	if config.BufferSize <= 0 {
+		config.BufferSize = defaultHubBufferSize
+	}
+	return &Hub{config: config, topics: make(map[string]map[*Subscription]struct{})}
+}
+
+// Subscription is a subscriber of one or more topics of a hub.
+type Subscription struct {
+	hub       *Hub
+	topics    []string
+	messages  chan HubMessage
+	closeOnce sync.Once
+	err       error
+	dropped   uint64
+}
+
+// Messages returns the channel of the messages published into the subscribed topics.
+// The channel is closed when the subscription is closed, see Err.
+func (subscription *Subscription) Messages() <-chan HubMessage { return subscription.messages }
+
+// Topics returns the subscribed topics.
+func (subscription *Subscription) Topics() []string { return subscription.topics }
+
+// Dropped returns the number of messages dropped because the subscription's buffer was full.
+func (subscription *Subscription) Dropped() uint64 { return atomic.LoadUint64(&subscription.dropped) }
+
+// Err returns the reason the subscription is closed: nil if it's unsubscribed (or still open),
+// ErrSlowConsumer if it was disconnected for being slow, and ErrHubClosed if the hub is closed.
+// It should be called once the messages channel is closed.
+func (subscription *Subscription) Err() error {
+	subscription.hub.mutex.RLock()
+	defer subscription.hub.mutex.RUnlock()
+	return subscription.err
+}
+
+// Unsubscribe removes the subscription from the hub, and closes its messages channel.
+func (subscription *Subscription) Unsubscribe() {
+	subscription.hub.mutex.Lock()
+	defer subscription.hub.mutex.Unlock()
+	subscription.hub.remove(subscription, nil)
+}
+
+// Subscribe subscribes to the given topics. If the hub is already closed, the subscription is closed with ErrHubClosed.
+func (hub *Hub) Subscribe(topics ...string) *Subscription {
+	subscription := &Subscription{hub: hub, topics: topics, messages: make(chan HubMessage, hub.config.BufferSize)}
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	if hub.closed {
+		subscription.close(ErrHubClosed)
+		return subscription
+	}
+	for _, topic := range topics {
+		subscribers, found := hub.topics[topic]
+		if !found {
+			subscribers = make(map[*Subscription]struct{})
+			hub.topics[topic] = subscribers
+		}
+		subscribers[subscription] = struct{}{}
+	}
+	return subscription
+}
+
+func (subscription *Subscription) close(err error) {
+	subscription.closeOnce.Do(func() {
+		subscription.err = err
+		close(subscription.messages)
+	})
+}
+
+// remove must be called while holding the write lock.
+func (hub *Hub) remove(subscription *Subscription, err error) {
+	for _, topic := range subscription.topics {
+		if subscribers, found := hub.topics[topic]; found {
+			delete(subscribers, subscription)
+			if len(subscribers) == 0 {
+				delete(hub.topics, topic)
+			}
+		}
+	}
+	subscription.close(err)
+}
+
+// Publish sends the data to the subscribers of the topic without blocking, and returns the number of subscribers
+// which received it. Subscribers with full buffers are handled based on the hub's slow consumer policy.
+func (hub *Hub) Publish(topic string, data any) int {
+	message := HubMessage{Topic: topic, Data: data}
+	var slowSubscribers []*Subscription
+	var delivered int
+	hub.mutex.RLock()
+	if hub.closed {
+		hub.mutex.RUnlock()
+		return 0
+	}
+	atomic.AddUint64(&hub.published, 1)
+	for subscription := range hub.topics[topic] {
+		select {
+		case subscription.messages <- message:
+			delivered++
+		default:
+			atomic.AddUint64(&subscription.dropped, 1)
+			atomic.AddUint64(&hub.dropped, 1)
+			if hub.config.SlowConsumerPolicy == DisconnectSlowConsumers {
+				slowSubscribers = append(slowSubscribers, subscription)
+			}
+		}
+	}
+	hub.mutex.RUnlock()
+	atomic.AddUint64(&hub.delivered, uint64(delivered))
+	if len(slowSubscribers) > 0 {
+		hub.mutex.Lock()
+		for _, subscription := range slowSubscribers {
+			if _, subscribed := hub.topics[topic][subscription]; subscribed {
+				hub.remove(subscription, ErrSlowConsumer)
+				atomic.AddUint64(&hub.disconnected, 1)
+			}
+		}
+		hub.mutex.Unlock()
+	}
+	return delivered
+}
+
+// Subscribers returns the number of subscribers of the given topic.
+func (hub *Hub) Subscribers(topic string) int {
+	hub.mutex.RLock()
+	defer hub.mutex.RUnlock()
+	return len(hub.topics[topic])
+}
+
+// Metrics returns a snapshot of the hub's statistics.
+func (hub *Hub) Metrics() HubMetrics {
+	hub.mutex.RLock()
+	defer hub.mutex.RUnlock()
+	metrics := HubMetrics{
+		Topics:       make(map[string]int, len(hub.topics)),
+		Published:    atomic.LoadUint64(&hub.published),
+		Delivered:    atomic.LoadUint64(&hub.delivered),
+		Dropped:      atomic.LoadUint64(&hub.dropped),
+		Disconnected: atomic.LoadUint64(&hub.disconnected),
+	}
+	subscriptions := make(map[*Subscription]struct{})
+	for topic, subscribers := range hub.topics {
+		metrics.Topics[topic] = len(subscribers)
+		for subscription := range subscribers {
+			subscriptions[subscription] = struct{}{}
+		}
+	}
+	metrics.Subscribers = len(subscriptions)
+	return metrics
+}
+
+// Close closes all the subscriptions with ErrHubClosed, publishing and subscribing afterwards do nothing.
+func (hub *Hub) Close() {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	if hub.closed {
+		return
+	}
+	hub.closed = true
+	for _, subscribers := range hub.topics {
+		for subscription := range subscribers {
+			subscription.close(ErrHubClosed)
+		}
+	}
+	hub.topics = make(map[string]map[*Subscription]struct{})
+}
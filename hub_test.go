@@ -0,0 +1,94 @@
+package stgin
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHub(t *testing.T) {
+	hub := NewHub(HubConfig{BufferSize: 2})
+	defer hub.Close()
+	scores := hub.Subscribe("scores")
+	everything := hub.Subscribe("scores", "news")
+
+	if delivered := hub.Publish("scores", 1); delivered != 2 {
+		t.Fatalf("expected the message to be delivered to 2 subscribers, got %d", delivered)
+	}
+	hub.Publish("news", "hello")
+	if message := <-scores.Messages(); message.Topic != "scores" || message.Data != 1 {
+		t.Fatalf("unexpected message: %v", message)
+	}
+	<-everything.Messages()
+	if message := <-everything.Messages(); message.Topic != "news" || message.Data != "hello" {
+		t.Fatalf("unexpected message: %v", message)
+	}
+
+	for i := 0; i < 3; i++ {
+		hub.Publish("scores", i)
+	}
+	if scores.Dropped() != 1 || len(scores.Messages()) != 2 {
+		t.Fatalf("expected the message exceeding the buffer to be dropped, got: %d dropped", scores.Dropped())
+	}
+	metrics := hub.Metrics()
+	if metrics.Subscribers != 2 || metrics.Topics["scores"] != 2 || metrics.Topics["news"] != 1 || metrics.Published != 5 || metrics.Dropped != 2 {
+		t.Fatalf("unexpected metrics: %+v", metrics)
+	}
+
+	scores.Unsubscribe()
+	if _, open := <-drain(scores.Messages()); open || scores.Err() != nil {
+		t.Fatal("unsubscribed subscription was not closed")
+	}
+	if hub.Subscribers("scores") != 1 {
+		t.Fatalf("unsubscribed subscription was not removed, got %d subscribers", hub.Subscribers("scores"))
+	}
+}
+
+// drain receives the buffered messages, and returns the channel.
+func drain(messages <-chan HubMessage) <-chan HubMessage {
+	for len(messages) > 0 {
+		<-messages
+	}
+	return messages
+}
+
+func TestHubSlowConsumers(t *testing.T) {
+	hub := NewHub(HubConfig{BufferSize: 1, SlowConsumerPolicy: DisconnectSlowConsumers})
+	defer hub.Close()
+	slow := hub.Subscribe("ticks")
+	hub.Publish("ticks", 1)
+	hub.Publish("ticks", 2)
+	if _, open := <-drain(slow.Messages()); open || slow.Err() != ErrSlowConsumer {
+		t.Fatalf("slow consumer was not disconnected, got: %v", slow.Err())
+	}
+	if metrics := hub.Metrics(); metrics.Subscribers != 0 || metrics.Disconnected != 1 {
+		t.Fatalf("unexpected metrics: %+v", metrics)
+	}
+}
+
+func TestServerShutdownClosesHubs(t *testing.T) {
+	server := &Server{addr: "127.0.0.1:0", notFoundAction: notFoundDefaultAction}
+	hub := server.NewHub(HubConfig{})
+	subscription := hub.Subscribe("news")
+	stopped := make(chan error, 1)
+	go func() { stopped <- server.Start() }()
+	for started := false; !started; {
+		server.lifecycle.Lock()
+		started = server.httpServer != nil
+		server.lifecycle.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, open := <-subscription.Messages(); open || subscription.Err() != ErrHubClosed {
+		t.Fatalf("subscription was not closed on shutdown, got: %v", subscription.Err())
+	}
+	if err := <-stopped; err != nil {
+		t.Fatalf("server did not stop gracefully: %v", err)
+	}
+	if hub.Publish("news", "late") != 0 || hub.Subscribe("news").Err() != ErrHubClosed {
+		t.Fatal("closed hub still accepts messages or subscribers")
+	}
+}
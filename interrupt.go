@@ -13,21 +13,19 @@ type Interrupt interface {
 	TriggerFor(request RequestContext, completeWith chan *Status)
 }
 
-func contextTimeoutExceededResponse() Status {
-	return Status{
-		StatusCode: http.StatusRequestTimeout,
-		Entity:     Text("408 - request timed out"),
-		doneAt:     time.Now(),
-	}
+func contextTimeoutExceededResponse(request RequestContext) Status {
+	status := failureResponse(request, http.StatusRequestTimeout, "request timed out")
+	status.doneAt = time.Now()
+	return status
 }
 
 type timeoutInterrupt struct {
 	timeout time.Duration
 }
 
-func (t timeoutInterrupt) TriggerFor(_ RequestContext, completeWith chan *Status) {
+func (t timeoutInterrupt) TriggerFor(request RequestContext, completeWith chan *Status) {
 	<-time.After(t.timeout)
-	result := contextTimeoutExceededResponse()
+	result := contextTimeoutExceededResponse(request)
 	completeWith <- &result
 }
 
@@ -0,0 +1,99 @@
+package stgin
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+)
+
+const octetStream = "application/octet-stream"
+
+// sniffLength is the number of bytes http.DetectContentType considers.
+const sniffLength = 512
+
+var customMimeTypes struct {
+	sync.RWMutex
+	types map[string]string
+}
+
+// AddMimeType registers the content type of the files with the given extension (like ".webmanifest"),
+// which is used by File, Attachment, static directories and MimeTypeOf, and takes precedence over the system mime table.
+// Text content types without a charset are registered with "charset=utf-8". It's safe to be called while serving requests.
+func AddMimeType(extension string, contentType string) error {
+	if !strings.HasPrefix(extension, ".") || len(extension) < 2 || strings.ContainsAny(extension, "/\\ ") {
+		return fmt.Errorf("'%s' is not a valid file extension, extensions must start with a dot", extension)
+	}
+	if _, _, err := mime.ParseMediaType(contentType); err != nil {
+		return fmt.Errorf("'%s' is not a valid content type: %s", contentType, err.Error())
+	}
+	customMimeTypes.Lock()
+	defer customMimeTypes.Unlock()
+	if customMimeTypes.types == nil {
+		customMimeTypes.types = make(map[string]string)
+	}
+	customMimeTypes.types[strings.ToLower(extension)] = withCharset(contentType)
+	return nil
+}
+
+// withCharset adds the utf-8 charset parameter to text content types which do not specify a charset.
+func withCharset(contentType string) string {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "text/") {
+		return contentType
+	}
+	if _, hasCharset := params["charset"]; hasCharset {
+		return contentType
+	}
+	return contentType + "; charset=utf-8"
+}
+
+// mimeTypeByExtension looks up the registered mime types, and the system mime table. It returns "" for unknown extensions.
+func mimeTypeByExtension(name string) string {
+	extension := strings.ToLower(path.Ext(name))
+	if extension == "" {
+		return ""
+	}
+	customMimeTypes.RLock()
+	contentType, found := customMimeTypes.types[extension]
+	customMimeTypes.RUnlock()
+	if found {
+		return contentType
+	}
+	return withCharset(mime.TypeByExtension(extension))
+}
+
+// MimeTypeOf returns the content type of a file based on its name, which is useful for custom entities.
+// Unknown and missing extensions result in "application/octet-stream".
+func MimeTypeOf(filename string) string {
+	if contentType := mimeTypeByExtension(filename); contentType != "" {
+		return contentType
+	}
+	return octetStream
+}
+
+// detectContentType returns the content type of a file based on its name, or by sniffing its content
+// if the extension is unknown (or missing). open can be nil if the content should not be sniffed (i.e., encoded files).
+func detectContentType(name string, open func() (fs.File, error)) string {
+	if contentType := mimeTypeByExtension(name); contentType != "" {
+		return contentType
+	}
+	if open == nil {
+		return octetStream
+	}
+	file, err := open()
+	if err != nil {
+		return octetStream
+	}
+	defer file.Close()
+	buffer := make([]byte, sniffLength)
+	read, _ := io.ReadFull(file, buffer)
+	if read == 0 {
+		return octetStream
+	}
+	return http.DetectContentType(buffer[:read])
+}
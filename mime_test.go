@@ -0,0 +1,70 @@
+package stgin
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestMimeTypeOf(t *testing.T) {
+	if err := AddMimeType(".webmanifest", "application/manifest+json"); err != nil {
+		t.Fatalf("could not add mime type: %s", err.Error())
+	}
+	if err := AddMimeType(".stgin", "text/x-stgin"); err != nil {
+		t.Fatalf("could not add mime type: %s", err.Error())
+	}
+	for _, invalid := range [][2]string{{"json", "application/json"}, {".", "text/plain"}, {".x", "not a/type/"}} {
+		if err := AddMimeType(invalid[0], invalid[1]); err == nil {
+			t.Errorf("invalid mime type %v got registered", invalid)
+		}
+	}
+	expected := map[string]string{
+		"style.CSS":            "text/css; charset=utf-8",
+		"module.wasm":          "application/wasm",
+		"site.webmanifest":     "application/manifest+json",
+		"routes.stgin":         "text/x-stgin; charset=utf-8",
+		"archive.unknown-type": octetStream,
+		"Makefile":             octetStream,
+	}
+	for name, contentType := range expected {
+		if detected := MimeTypeOf(name); detected != contentType {
+			t.Errorf("expected %s for %s, got %s", contentType, name, detected)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			_ = AddMimeType(".concurrent"+strconv.Itoa(i), "application/x-concurrent")
+		}(i)
+		go func() {
+			defer wg.Done()
+			_ = MimeTypeOf("site.webmanifest")
+		}()
+	}
+	wg.Wait()
+}
+
+func TestFileContentType(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"LICENSE":   "MIT License",
+		"page":      "<!DOCTYPE html><html></html>",
+		"data.json": "{}",
+	}
+	expected := map[string]string{
+		"LICENSE":   "text/plain; charset=utf-8",
+		"page":      "text/html; charset=utf-8",
+		"data.json": "application/json",
+	}
+	for name, content := range files {
+		_ = os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+		if contentType := File(filepath.Join(dir, name)).Entity.ContentType(); contentType != expected[name] {
+			t.Errorf("expected %s for %s, got %s", expected[name], name, contentType)
+		}
+	}
+}
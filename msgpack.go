@@ -0,0 +1,322 @@
+package stgin
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+const applicationMsgpack = "application/msgpack"
+
+// msgpackTimestamp is the extension type of timestamps.
+const msgpackTimestamp = -1
+
+// MsgpackCodec is the built-in application/msgpack codec. Struct fields are encoded as maps, named by their `msgpack`
+// tags (supporting "-" and omitempty), or their names. Integers and lengths are encoded in their smallest representation,
+// floats as float 64 and times as RFC3339 strings. Decoding supports all the formats of the specification, but among
+// the extension types only the timestamp (-1) is supported, other extensions are rejected with an error.
+type MsgpackCodec struct{}
+
+func (codec MsgpackCodec) Marshal(a any) ([]byte, error) {
+	tree, err := toTree(reflect.ValueOf(a), "msgpack")
+	if err != nil {
+		return nil, err
+	}
+	return appendMsgpack(nil, tree), nil
+}
+
+func (codec MsgpackCodec) Unmarshal(data []byte, a any) error {
+	target := reflect.ValueOf(a)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return fmt.Errorf("cannot decode into non-pointer %T", a)
+	}
+	decoder := &msgpackDecoder{data: data}
+	tree, err := decoder.decode(0)
+	if err != nil {
+		return err
+	}
+	if decoder.offset != len(data) {
+		return errors.New("invalid data after top-level value")
+	}
+	return assignTree(target.Elem(), tree, "msgpack", "")
+}
+
+func appendUint(buffer []byte, prefix byte, value uint64, size int) []byte {
+	buffer = append(buffer, prefix)
+	for shift := (size - 1) * 8; shift >= 0; shift -= 8 {
+		buffer = append(buffer, byte(value>>uint(shift)))
+	}
+	return buffer
+}
+
+// appendLength appends the header of strings, binaries, arrays and maps, using the smallest representation.
+func appendLength(buffer []byte, length int, fixPrefix byte, fixLimit int, prefix8 byte, prefix16 byte, prefix32 byte) []byte {
+	switch {
+	case length < fixLimit:
+		return append(buffer, fixPrefix|byte(length))
+	case length <= math.MaxUint8 && prefix8 != 0:
+		return appendUint(buffer, prefix8, uint64(length), 1)
+	case length <= math.MaxUint16:
+		return appendUint(buffer, prefix16, uint64(length), 2)
+	default:
+		return appendUint(buffer, prefix32, uint64(length), 4)
+	}
+}
+
+func appendMsgpack(buffer []byte, tree any) []byte {
+	switch value := tree.(type) {
+	case nil:
+		return append(buffer, 0xc0)
+	case bool:
+		if value {
+			return append(buffer, 0xc3)
+		}
+		return append(buffer, 0xc2)
+	case int64:
+		if value >= 0 {
+			return appendMsgpack(buffer, uint64(value))
+		}
+		switch {
+		case value >= -32:
+			return append(buffer, byte(value))
+		case value >= math.MinInt8:
+			return appendUint(buffer, 0xd0, uint64(value), 1)
+		case value >= math.MinInt16:
+			return appendUint(buffer, 0xd1, uint64(value), 2)
+		case value >= math.MinInt32:
+			return appendUint(buffer, 0xd2, uint64(value), 4)
+		default:
+			return appendUint(buffer, 0xd3, uint64(value), 8)
+		}
+	case uint64:
+		switch {
+		case value < 128:
+			return append(buffer, byte(value))
+		case value <= math.MaxUint8:
+			return appendUint(buffer, 0xcc, value, 1)
+		case value <= math.MaxUint16:
+			return appendUint(buffer, 0xcd, value, 2)
+		case value <= math.MaxUint32:
+			return appendUint(buffer, 0xce, value, 4)
+		default:
+			return appendUint(buffer, 0xcf, value, 8)
+		}
+	case float64:
+		return appendUint(buffer, 0xcb, math.Float64bits(value), 8)
+	case string:
+		buffer = appendLength(buffer, len(value), 0xa0, 32, 0xd9, 0xda, 0xdb)
+		return append(buffer, value...)
+	case []byte:
+		buffer = appendLength(buffer, len(value), 0xc4, 0, 0xc4, 0xc5, 0xc6)
+		return append(buffer, value...)
+	case []any:
+		buffer = appendLength(buffer, len(value), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, item := range value {
+			buffer = appendMsgpack(buffer, item)
+		}
+		return buffer
+	case treeMap:
+		buffer = appendLength(buffer, len(value), 0x80, 16, 0, 0xde, 0xdf)
+		for _, entry := range value {
+			buffer = appendMsgpack(buffer, entry.key)
+			buffer = appendMsgpack(buffer, entry.value)
+		}
+		return buffer
+	default:
+		// toTree does not produce other values
+		panic(fmt.Sprintf("unexpected value of type %T", tree))
+	}
+}
+
+type msgpackDecoder struct {
+	data   []byte
+	offset int
+}
+
+var errMsgpackTruncated = errors.New("unexpected end of data")
+
+func (decoder *msgpackDecoder) read(size int) ([]byte, error) {
+	if size < 0 || len(decoder.data)-decoder.offset < size {
+		return nil, errMsgpackTruncated
+	}
+	bytes := decoder.data[decoder.offset : decoder.offset+size]
+	decoder.offset += size
+	return bytes, nil
+}
+
+func (decoder *msgpackDecoder) readUint(size int) (uint64, error) {
+	bytes, err := decoder.read(size)
+	if err != nil {
+		return 0, err
+	}
+	var value uint64
+	for _, b := range bytes {
+		value = value<<8 | uint64(b)
+	}
+	return value, nil
+}
+
+func (decoder *msgpackDecoder) readBytes(size int) ([]byte, error) {
+	bytes, err := decoder.read(size)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{}, bytes...), nil
+}
+
+func (decoder *msgpackDecoder) readString(size int) (any, error) {
+	bytes, err := decoder.read(size)
+	if err != nil {
+		return nil, err
+	}
+	return string(bytes), nil
+}
+
+func (decoder *msgpackDecoder) readArray(size int, depth int) (any, error) {
+	// each item takes at least a byte, which keeps the allocation proportional to the data
+	if size > len(decoder.data)-decoder.offset {
+		return nil, errMsgpackTruncated
+	}
+	items := make([]any, size)
+	for i := range items {
+		item, err := decoder.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+func (decoder *msgpackDecoder) readMap(size int, depth int) (any, error) {
+	if size > (len(decoder.data)-decoder.offset)/2 {
+		return nil, errMsgpackTruncated
+	}
+	entries := make(treeMap, size)
+	for i := range entries {
+		key, err := decoder.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		switch key.(type) {
+		case []any, treeMap:
+			return nil, errors.New("map keys must be scalar values")
+		}
+		value, err := decoder.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = treeEntry{key: key, value: value}
+	}
+	return entries, nil
+}
+
+func (decoder *msgpackDecoder) readExtension(size int) (any, error) {
+	tpe, err := decoder.read(1)
+	if err != nil {
+		return nil, err
+	}
+	data, err := decoder.read(size)
+	if err != nil {
+		return nil, err
+	}
+	if int8(tpe[0]) != msgpackTimestamp {
+		return nil, fmt.Errorf("unsupported extension type %d (only timestamps are supported)", int8(tpe[0]))
+	}
+	switch size {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC(), nil
+	case 8:
+		value := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(value&0x3ffffffff), int64(value>>34)).UTC(), nil
+	case 12:
+		return time.Unix(int64(binary.BigEndian.Uint64(data[4:])), int64(binary.BigEndian.Uint32(data))).UTC(), nil
+	default:
+		return nil, fmt.Errorf("invalid timestamp of %d bytes", size)
+	}
+}
+
+func (decoder *msgpackDecoder) decode(depth int) (any, error) {
+	if depth > maxTreeDepth {
+		return nil, errors.New("exceeded max nesting depth")
+	}
+	header, err := decoder.read(1)
+	if err != nil {
+		return nil, err
+	}
+	b := header[0]
+	switch {
+	case b <= 0x7f:
+		return uint64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xe0 == 0xa0:
+		return decoder.readString(int(b & 0x1f))
+	case b&0xf0 == 0x90:
+		return decoder.readArray(int(b&0x0f), depth)
+	case b&0xf0 == 0x80:
+		return decoder.readMap(int(b&0x0f), depth)
+	}
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return decoder.readUint(1 << (b - 0xcc))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (b - 0xd0)
+		value, err := decoder.readUint(size)
+		if err != nil {
+			return nil, err
+		}
+		// sign extension
+		shift := uint(64 - size*8)
+		return int64(value<<shift) >> shift, nil
+	case 0xca:
+		value, err := decoder.readUint(4)
+		return float64(math.Float32frombits(uint32(value))), err
+	case 0xcb:
+		value, err := decoder.readUint(8)
+		return math.Float64frombits(value), err
+	case 0xd9, 0xda, 0xdb:
+		size, err := decoder.readUint(1 << (b - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.readString(int(size))
+	case 0xc4, 0xc5, 0xc6:
+		size, err := decoder.readUint(1 << (b - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.readBytes(int(size))
+	case 0xdc, 0xdd:
+		size, err := decoder.readUint(2 << (b - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.readArray(int(size), depth)
+	case 0xde, 0xdf:
+		size, err := decoder.readUint(2 << (b - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.readMap(int(size), depth)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return decoder.readExtension(1 << (b - 0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		size, err := decoder.readUint(1 << (b - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.readExtension(int(size))
+	default:
+		return nil, fmt.Errorf("invalid type byte 0x%x", b)
+	}
+}
@@ -0,0 +1,164 @@
+package stgin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type msgpackItem struct {
+	Id      int64    `msgpack:"id"`
+	Name    string   `msgpack:"name"`
+	Price   float64  `msgpack:"price,omitempty"`
+	Tags    []string `msgpack:"tags"`
+	Data    []byte   `msgpack:"data"`
+	Hidden  string   `msgpack:"-"`
+	Visible bool
+}
+
+func TestMsgpackEncoding(t *testing.T) {
+	cases := map[string]any{
+		"c0":                 nil,
+		"c3":                 true,
+		"7f":                 127,
+		"cc80":               uint8(128),
+		"e0":                 -32,
+		"d0df":               -33,
+		"cd0100":             256,
+		"d1ff7f":             -129,
+		"ceffffffff":         uint32(math.MaxUint32),
+		"cfffffffffffffffff": uint64(math.MaxUint64),
+		"d38000000000000000": int64(math.MinInt64),
+		"cb3ff8000000000000": 1.5,
+		"a3616263":           "abc",
+		"c40201ff":           []byte{1, 255},
+		"920102":             []int{1, 2},
+		"82a16101a16202":     map[string]int{"b": 2, "a": 1},
+	}
+	for expected, value := range cases {
+		encoded, err := MsgpackCodec{}.Marshal(value)
+		if err != nil || hex.EncodeToString(encoded) != expected {
+			t.Errorf("expected %s for %v, got %x (%v)", expected, value, encoded, err)
+		}
+	}
+	long, _ := MsgpackCodec{}.Marshal(strings.Repeat("x", 40))
+	if !bytes.HasPrefix(long, []byte{0xd9, 40}) {
+		t.Errorf("expected str8 for long strings, got %x", long[:2])
+	}
+}
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	item := msgpackItem{Id: -5, Name: "pen", Tags: []string{"a", "b"}, Data: []byte{0, 1}, Hidden: "x", Visible: true}
+	encoded, err := MsgpackCodec{}.Marshal(&item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded msgpackItem
+	if err = (MsgpackCodec{}).Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	item.Hidden = ""
+	if !reflect.DeepEqual(decoded, item) {
+		t.Fatalf("expected %+v, got %+v", item, decoded)
+	}
+	var generic any
+	if err = (MsgpackCodec{}).Unmarshal(encoded, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if fields := generic.(map[string]any); fields["name"] != "pen" || fields["id"] != int64(-5) || len(fields) != 5 {
+		t.Fatalf("unexpected generic value %v", generic)
+	}
+}
+
+func TestMsgpackIntegerWidths(t *testing.T) {
+	cases := map[string]any{
+		"7f":                 uint64(127),
+		"ff":                 int64(-1),
+		"ccff":               uint64(math.MaxUint8),
+		"cdffff":             uint64(math.MaxUint16),
+		"ceffffffff":         uint64(math.MaxUint32),
+		"cfffffffffffffffff": uint64(math.MaxUint64),
+		"d080":               int64(math.MinInt8),
+		"d18000":             int64(math.MinInt16),
+		"d280000000":         int64(math.MinInt32),
+		"d38000000000000000": int64(math.MinInt64),
+		"d0ff":               int64(-1),
+		"ca3fc00000":         1.5,
+	}
+	for data, expected := range cases {
+		decoded, _ := hex.DecodeString(data)
+		var value any
+		if err := (MsgpackCodec{}).Unmarshal(decoded, &value); err != nil || value != expected {
+			t.Errorf("expected %v (%T) for %s, got %v (%T), %v", expected, expected, data, value, value, err)
+		}
+	}
+	var wide int64
+	if err := (MsgpackCodec{}).Unmarshal([]byte{0xcd, 0x01, 0x00}, &wide); err != nil || wide != 256 {
+		t.Fatalf("narrow integers were not decoded into wider ones, got %d (%v)", wide, err)
+	}
+	var unsigned uint16
+	if err := (MsgpackCodec{}).Unmarshal([]byte{0xd0, 0xff}, &unsigned); err == nil {
+		t.Fatalf("negative integers were decoded into unsigned ones, got %d", unsigned)
+	}
+}
+
+func TestMsgpackExtensions(t *testing.T) {
+	timestamps := map[string]time.Time{
+		"d6ff0000003c":                   time.Unix(60, 0),
+		"d7ff000000040000003c":           time.Unix(60, 1),
+		"c70cff000000010000000000000000": time.Unix(0, 1),
+		"c70cff00000000ffffffffffffffff": time.Unix(-1, 0),
+	}
+	for data, expected := range timestamps {
+		decoded, _ := hex.DecodeString(data)
+		var timestamp time.Time
+		if err := (MsgpackCodec{}).Unmarshal(decoded, &timestamp); err != nil || !timestamp.Equal(expected) {
+			t.Errorf("expected %v for %s, got %v (%v)", expected, data, timestamp, err)
+		}
+	}
+	invalid := map[string]string{
+		"d40501":     "unsupported extension type 5",
+		"c7020a0102": "unsupported extension type 10",
+		"d5ff0000":   "invalid timestamp of 2 bytes",
+		"c70cff00":   "unexpected end of data",
+	}
+	for data, message := range invalid {
+		decoded, _ := hex.DecodeString(data)
+		var value any
+		if err := (MsgpackCodec{}).Unmarshal(decoded, &value); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("expected an error containing %q for %s, got %v", message, data, err)
+		}
+	}
+}
+
+func TestMsgpackDecoding(t *testing.T) {
+	var timestamp time.Time
+	// timestamp 32 extension
+	if err := (MsgpackCodec{}).Unmarshal([]byte{0xd6, 0xff, 0, 0, 0, 60}, &timestamp); err != nil || !timestamp.Equal(time.Unix(60, 0)) {
+		t.Fatalf("timestamp was not decoded, got %v (%v)", timestamp, err)
+	}
+	var small int8
+	invalid := map[string]any{
+		"cd0100":     &small,
+		"92":         &[]int{},
+		"dcffff":     &[]int{},
+		"c1":         new(any),
+		"0101":       new(int),
+		"a26869":     new(int),
+		"81a2696401": new(string),
+	}
+	for data, target := range invalid {
+		decoded, _ := hex.DecodeString(data)
+		if err := (MsgpackCodec{}).Unmarshal(decoded, target); err == nil {
+			t.Errorf("expected an error decoding %s into %T", data, target)
+		}
+	}
+	var item msgpackItem
+	if err := (MsgpackCodec{}).Unmarshal([]byte{0x81, 0xa2, 'i', 'd', 0xa3, 'a', 'b', 'c'}, &item); err == nil || !strings.Contains(err.Error(), "'id'") {
+		t.Fatalf("expected the invalid field to be reported, got %v", err)
+	}
+}
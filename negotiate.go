@@ -0,0 +1,137 @@
+package stgin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// responseCodec encodes the negotiated response objects into a media type.
+type responseCodec struct {
+	mediaType string
+	marshal   func(a any) ([]byte, error)
+}
+
+// mediaRangeMatch returns how specifically the media range (like "text/*") matches the media type, or -1 if it doesn't.
+func mediaRangeMatch(mediaRange string, mediaType string) int {
+	if mediaRange == mediaType {
+		return 2
+	}
+	if mediaRange == "*/*" {
+		return 0
+	}
+	if strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*")) {
+		return 1
+	}
+	return -1
+}
+
+// negotiateCodec chooses the codec which is accepted with the highest quality, the quality of each codec is decided
+// by the most specific media range matching it. Ties are broken by the order of the Accept header, and then the order
+// of the codecs. Empty Accept headers accept the first codec.
+func negotiateCodec(accept string, codecs []responseCodec) (responseCodec, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return codecs[0], true
+	}
+	mediaRanges := parseQualityList(accept)
+	var chosen responseCodec
+	bestQuality, bestIndex := 0.0, 0
+	for _, codec := range codecs {
+		specificity, quality, index := -1, 0.0, 0
+		for _, mediaRange := range mediaRanges {
+			if match := mediaRangeMatch(mediaRange.value, codec.mediaType); match > specificity {
+				specificity, quality, index = match, mediaRange.quality, mediaRange.index
+			}
+		}
+		if specificity < 0 || quality == 0 {
+			continue
+		}
+		if quality > bestQuality || (quality == bestQuality && index < bestIndex) {
+			chosen, bestQuality, bestIndex = codec, quality, index
+		}
+	}
+	return chosen, bestQuality > 0
+}
+
+func withoutCodec(codecs []responseCodec, mediaType string) []responseCodec {
+	remaining := make([]responseCodec, 0, len(codecs)-1)
+	for _, codec := range codecs {
+		if codec.mediaType != mediaType {
+			remaining = append(remaining, codec)
+		}
+	}
+	return remaining
+}
+
+func addVary(headers http.Header, header string) {
+	for _, value := range headers.Values("Vary") {
+		for _, varied := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(varied), header) {
+				return
+			}
+		}
+	}
+	headers.Add("Vary", header)
+}
+
+// negotiatedEntity is encoded once it's being written, based on the Accept header of the request.
+type negotiatedEntity struct {
+	obj any
+}
+
+// Negotiate creates an entity which is encoded using one of the codecs of the server or controller (JSON, XML and
+// plain text by default), based on the Accept header of the request (considering the q-values), preferring the codecs
+// in the order of registration. Codecs which cannot encode the object are skipped in favor of the next acceptable one,
+// and if none of them is acceptable, the response becomes 406 not acceptable.
+// The response varies on the Accept header. Since the encoding is decided when the response is written,
+// response listeners see the entity as JSON.
+func Negotiate(obj any) ResponseEntity {
+	return negotiatedEntity{obj: obj}
+}
+
+func (ne negotiatedEntity) ContentType() string {
+	return applicationJson
+}
+
+func (ne negotiatedEntity) Bytes() ([]byte, error) {
+	return jsonEntity{obj: ne.obj}.Bytes()
+}
+
+func (ne negotiatedEntity) serve(writer http.ResponseWriter, request *http.Request, statusCode int) error {
+	addVary(writer.Header(), "Accept")
+	codecs := codecsOf(request)
+	negotiableCodecs := codecs.responseCodecs()
+	var codec responseCodec
+	var content []byte
+	var err error
+	acceptable := false
+	for candidates := negotiableCodecs; len(candidates) > 0; candidates = withoutCodec(candidates, codec.mediaType) {
+		if codec, acceptable = negotiateCodec(request.Header.Get("Accept"), candidates); !acceptable {
+			break
+		}
+		if content, err = codec.marshal(ne.obj); err == nil {
+			break
+		}
+		acceptable = false
+	}
+	if !acceptable {
+		mediaTypes := make([]string, len(negotiableCodecs))
+		for i, available := range negotiableCodecs {
+			mediaTypes[i] = available.mediaType
+		}
+		rc := requestContextFromHttpRequest(request, writer, nil)
+		problem := failureResponse(rc, http.StatusNotAcceptable, "none of the available media types is acceptable: "+strings.Join(mediaTypes, ", "))
+		codec = responseCodec{mediaType: problem.Entity.ContentType()}
+		statusCode = problem.StatusCode
+		content, _, err = marshall(problem.Entity, codecs)
+	}
+	if err != nil {
+		return fmt.Errorf("could not encode the negotiated entity as %s: %w", codec.mediaType, err)
+	}
+	writer.Header().Set(contentTypeKey, codec.mediaType)
+	writer.WriteHeader(statusCode)
+	if request.Method != http.MethodHead {
+		_, _ = writer.Write(content)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package stgin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateCodec(t *testing.T) {
+	cases := map[string]string{
+		"":                applicationJson,
+		"*/*":             applicationJson,
+		"application/xml": applicationXml,
+		"text/html, application/xml;q=0.9, */*;q=0.1": applicationXml,
+		"application/xml, application/json":           applicationXml,
+		"application/json;q=0.5, text/*":              plainText,
+		"*/*;q=0.8, application/json;q=0":             applicationXml,
+		"text/html":                                   "",
+		"application/*;q=0":                           "",
+	}
+	for accept, expected := range cases {
+		codec, acceptable := negotiateCodec(accept, NewCodecRegistry().responseCodecs())
+		if (expected == "") == acceptable || codec.mediaType != expected {
+			t.Errorf("expected %q for Accept: %q, got %q (%v)", expected, accept, codec.mediaType, acceptable)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	controller := NewController("Negotiation", "")
+	controller.AddRoutes(GET("/greeting", func(RequestContext) Status {
+		return Ok(Negotiate(greetResponse{Greeting: "hello"}))
+	}))
+	handler := (&Server{Controllers: []*Controller{controller}, notFoundAction: notFoundDefaultAction}).HttpHandler()
+	serve := func(accept string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest("GET", "/greeting", nil)
+		request.Header.Set("Accept", accept)
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	response := serve("application/xml;q=0.9, application/json;q=0.8")
+	if response.Body.String() != "<greetResponse><greeting>hello</greeting></greetResponse>" || response.Header().Get("Content-Type") != applicationXml {
+		t.Fatalf("xml was not negotiated, got: %s %v", response.Body.String(), response.Header())
+	}
+	if response.Header().Get("Vary") != "Accept" {
+		t.Fatal("negotiated response does not vary on Accept")
+	}
+	if response = serve("application/json"); response.Body.String() != `{"greeting":"hello"}` {
+		t.Fatalf("json was not negotiated, got: %s", response.Body.String())
+	}
+	if response = serve("image/png"); response.Code != http.StatusNotAcceptable {
+		t.Fatalf("expected 406 for unacceptable media types, got %d", response.Code)
+	}
+	if response = serve("text/plain"); response.Code != http.StatusNotAcceptable {
+		t.Fatalf("expected 406 for structs which cannot be encoded as text, got %d: %s", response.Code, response.Body.String())
+	}
+	if response = serve("text/*, application/xml;q=0.5"); response.Header().Get("Content-Type") != applicationXml {
+		t.Fatalf("expected the next acceptable codec after text, got: %s %v", response.Body.String(), response.Header())
+	}
+}
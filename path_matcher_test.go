@@ -54,8 +54,8 @@ func TestAddMatchingPattern(t *testing.T) {
 	}
 	validQueryOrPathParam := "johnDoe"
 	acceptsValidParam := acceptsAllQueries(
-		map[string]string {
-			"test": "john",
+		queryDecl {
+			"test": {tpe: "john"},
 		},
 		map[string][]string {
 			"test": {validQueryOrPathParam},
@@ -66,8 +66,8 @@ func TestAddMatchingPattern(t *testing.T) {
 	}
 	invalidQueryOrParam := "doeJohn"
 	acceptsInvalidParam := acceptsAllQueries(
-		map[string]string {
-			"test": "john",
+		queryDecl {
+			"test": {tpe: "john"},
 		},
 		map[string][]string {
 			"test": {invalidQueryOrParam},
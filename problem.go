@@ -0,0 +1,207 @@
+package stgin
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+const applicationProblemJson = "application/problem+json"
+
+// Problem is an RFC 7807 problem details ResponseEntity (application/problem+json), which all the errors of stgin use.
+// Extensions are encoded as the members of the problem, next to the standard ones.
+type Problem struct {
+	// Type is a URI reference identifying the problem type, which defaults to "about:blank".
+	Type string
+	// Title is a short summary of the problem type, like the status text of the status code.
+	Title string
+	// Status is the http status code of the problem.
+	Status int
+	// Detail explains this occurrence of the problem.
+	Detail string
+	// Instance is a URI reference identifying this occurrence of the problem, like the path of the request.
+	Instance   string
+	Extensions map[string]any
+}
+
+// NewProblem returns a Problem of the status code, titled by its status text, with the given detail.
+func NewProblem(statusCode int, detail string) Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(statusCode),
+		Status: statusCode,
+		Detail: detail,
+	}
+}
+
+// With returns a new Problem, which has the given extension member as well.
+func (problem Problem) With(key string, value any) Problem {
+	extensions := make(map[string]any, len(problem.Extensions)+1)
+	for k, v := range problem.Extensions {
+		extensions[k] = v
+	}
+	extensions[key] = value
+	problem.Extensions = extensions
+	return problem
+}
+
+func (problem Problem) ContentType() string { return applicationProblemJson }
+
+func (problem Problem) Bytes() ([]byte, error) {
+	return problem.encode(DefaultCodecs)
+}
+
+func (problem Problem) encode(codecs *CodecRegistry) ([]byte, error) {
+	return codecs.lookup(applicationProblemJson).Marshal(problem)
+}
+
+type problemMember struct {
+	key   string
+	value any
+}
+
+// MarshalJSON encodes the standard members first (omitting the empty ones), followed by the sorted extensions.
+// Extensions cannot override the standard members.
+func (problem Problem) MarshalJSON() ([]byte, error) {
+	members := []problemMember{
+		{"type", problem.Type}, {"title", problem.Title}, {"status", problem.Status},
+		{"detail", problem.Detail}, {"instance", problem.Instance},
+	}
+	standard := make(map[string]bool, len(members))
+	var result []problemMember
+	for _, member := range members {
+		standard[member.key] = true
+		if member.value != "" && member.value != 0 {
+			result = append(result, member)
+		}
+	}
+	keys := make([]string, 0, len(problem.Extensions))
+	for key := range problem.Extensions {
+		if !standard[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		result = append(result, problemMember{key, problem.Extensions[key]})
+	}
+	buffer := []byte{'{'}
+	for i, member := range result {
+		if i != 0 {
+			buffer = append(buffer, ',')
+		}
+		key, _ := json.Marshal(member.key)
+		value, err := json.Marshal(member.value)
+		if err != nil {
+			return nil, err
+		}
+		buffer = append(append(append(buffer, key...), ':'), value...)
+	}
+	return append(buffer, '}'), nil
+}
+
+// UnmarshalJSON decodes the standard members, and keeps the others as the extensions.
+func (problem *Problem) UnmarshalJSON(data []byte) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	*problem = Problem{}
+	targets := map[string]any{
+		"type": &problem.Type, "title": &problem.Title, "status": &problem.Status,
+		"detail": &problem.Detail, "instance": &problem.Instance,
+	}
+	for key, raw := range members {
+		if target, isStandard := targets[key]; isStandard {
+			if err := json.Unmarshal(raw, target); err != nil {
+				return err
+			}
+			continue
+		}
+		var value any
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return err
+		}
+		if problem.Extensions == nil {
+			problem.Extensions = map[string]any{}
+		}
+		problem.Extensions[key] = value
+	}
+	return nil
+}
+
+var problemCustomizer struct {
+	sync.RWMutex
+	customize func(request RequestContext, problem Problem) Problem
+}
+
+// CustomizeProblems sets a function which is applied to all the problems created by stgin (like 404s, binding failures
+// and panics handled by the default error handler) before they're returned, i.e., to link the types to documentation,
+// or to add extensions like a trace id.
+func CustomizeProblems(customize func(request RequestContext, problem Problem) Problem) {
+	problemCustomizer.Lock()
+	problemCustomizer.customize = customize
+	problemCustomizer.Unlock()
+}
+
+// problemResponse returns the status of the problem, which occurred for the request.
+func problemResponse(request RequestContext, problem Problem) Status {
+	if problem.Instance == "" {
+		problem.Instance = request.Url
+	}
+	problemCustomizer.RLock()
+	customize := problemCustomizer.customize
+	problemCustomizer.RUnlock()
+	if customize != nil {
+		problem = customize(request, problem)
+	}
+	return Status{
+		StatusCode: problem.Status,
+		Entity:     problem,
+		Headers:    http.Header{},
+	}
+}
+
+// failureResponse is the problem response of a status code with the given detail.
+func failureResponse(request RequestContext, statusCode int, detail string) Status {
+	return problemResponse(request, NewProblem(statusCode, detail))
+}
+
+// writeProblem writes the problem response of a status code with the given detail, for the entities which fail
+// while they're being served (like files which cannot be read).
+func writeProblem(writer http.ResponseWriter, request *http.Request, statusCode int, detail string) {
+	status := failureResponse(requestContextFromHttpRequest(request, writer, nil), statusCode, detail)
+	content, contentType, err := marshall(status.Entity, codecsOf(request))
+	if err != nil {
+		http.Error(writer, detail, statusCode)
+		return
+	}
+	// the headers of the failed entity (like Content-Disposition) do not apply to the problem
+	for _, key := range []string{contentDispositionKey, "Content-Encoding", "Content-Length", "ETag", "Last-Modified"} {
+		writer.Header().Del(key)
+	}
+	writer.Header().Set(contentTypeKey, contentType)
+	writer.WriteHeader(statusCode)
+	if request.Method != http.MethodHead {
+		_, _ = writer.Write(content)
+	}
+}
+
+// deferredProblem is the problem of a failure which happens before the request is known (i.e., in File),
+// which is built for the request once it's being served.
+type deferredProblem struct {
+	statusCode int
+	detail     string
+}
+
+func (dp deferredProblem) ContentType() string { return applicationProblemJson }
+
+func (dp deferredProblem) Bytes() ([]byte, error) {
+	return NewProblem(dp.statusCode, dp.detail).Bytes()
+}
+
+func (dp deferredProblem) serve(writer http.ResponseWriter, request *http.Request, _ int) error {
+	writeProblem(writer, request, dp.statusCode, dp.detail)
+	return nil
+}
@@ -0,0 +1,106 @@
+package stgin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProblem_JSON(t *testing.T) {
+	problem := NewProblem(http.StatusConflict, "the name is taken").
+		With("name", "john").
+		With("status", "overridden").
+		With("alternatives", []string{"john2"})
+	bytes, err := problem.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"type":"about:blank","title":"Conflict","status":409,"detail":"the name is taken","alternatives":["john2"],"name":"john"}`
+	if string(bytes) != expected {
+		t.Fatalf("expected %s, got %s", expected, bytes)
+	}
+	if problem.ContentType() != "application/problem+json" {
+		t.Fatalf("unexpected content type %q", problem.ContentType())
+	}
+	var decoded Problem
+	if err = json.Unmarshal(bytes, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	want := Problem{Type: "about:blank", Title: "Conflict", Status: 409, Detail: "the name is taken",
+		Extensions: map[string]any{"name": "john", "alternatives": []any{"john2"}}}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Fatalf("expected %+v, got %+v", want, decoded)
+	}
+	if bytes, _ = (Problem{Status: 500}).Bytes(); string(bytes) != `{"status":500}` {
+		t.Fatalf("empty members were not omitted, got %s", bytes)
+	}
+	if _, exists := problem.With("other", 1).Extensions["other"]; !exists || len(problem.Extensions) != 3 {
+		t.Fatalf("With should not modify the original problem")
+	}
+}
+
+func TestProblem_BuiltinErrors(t *testing.T) {
+	controller := NewController("Problems", "")
+	controller.AddRoutes(
+		GET("/panic", func(RequestContext) Status { panic("boom") }),
+		POST("/parse", func(request RequestContext) Status {
+			var body map[string]any
+			request.Body().JSONInto(&body)
+			return Ok(Json(body))
+		}),
+		PUT("/items/$id:int", func(RequestContext) Status { return Ok(Empty()) }),
+		DELETE("/items/$id:int", func(RequestContext) Status { return Ok(Empty()) }),
+	)
+	server := &Server{Controllers: []*Controller{controller}, notFoundAction: notFoundDefaultAction, errorAction: errorAction}
+	serve := func(method string, path string, body string) (*httptest.ResponseRecorder, Problem) {
+		request := httptest.NewRequest(method, path, nil)
+		if body != "" {
+			request = httptest.NewRequest(method, path, strings.NewReader(body))
+			request.Header.Set("Content-Type", "application/json")
+		}
+		recorder := httptest.NewRecorder()
+		server.HttpHandler().ServeHTTP(recorder, request)
+		var problem Problem
+		if err := json.Unmarshal(recorder.Body.Bytes(), &problem); err != nil {
+			t.Fatalf("%s %s was not a problem: %s", method, path, recorder.Body.String())
+		}
+		if recorder.Header().Get("Content-Type") != applicationProblemJson || problem.Status != recorder.Code {
+			t.Fatalf("%s %s was not a problem: %d %v", method, path, recorder.Code, recorder.Header())
+		}
+		return recorder, problem
+	}
+
+	if response, problem := serve(http.MethodGet, "/missing", ""); response.Code != 404 || problem.Instance != "/missing" {
+		t.Fatalf("unexpected not found problem: %+v", problem)
+	}
+	if response, problem := serve(http.MethodGet, "/panic", ""); response.Code != 500 || problem.Title != "Internal Server Error" {
+		t.Fatalf("unexpected panic problem: %+v", problem)
+	}
+	if response, problem := serve(http.MethodPost, "/parse", "{"); response.Code != 400 || problem.Detail == "" {
+		t.Fatalf("unexpected parse problem: %+v", problem)
+	}
+	if response, problem := serve(http.MethodGet, "/items/12", ""); response.Code != 405 || response.Header().Get("Allow") != "DELETE, PUT" {
+		t.Fatalf("unexpected method not allowed problem: %+v, %v", problem, response.Header())
+	}
+	if response, _ := serve(http.MethodGet, "/items/twelve", ""); response.Code != 404 {
+		t.Fatalf("expected not found for a path which no method answers, got %d", response.Code)
+	}
+
+	CustomizeProblems(func(request RequestContext, problem Problem) Problem {
+		problem.Type = "https://example.com/problems/" + http.StatusText(problem.Status)
+		return problem.With("method", request.Method)
+	})
+	defer CustomizeProblems(nil)
+	_, problem := serve(http.MethodGet, "/missing", "")
+	if problem.Type != "https://example.com/problems/Not Found" || problem.Extensions["method"] != "GET" {
+		t.Fatalf("problem was not customized, got %+v", problem)
+	}
+
+	status := contextTimeoutExceededResponse(RequestContext{Url: "/slow"})
+	if problem, isProblem := status.Entity.(Problem); !isProblem || status.StatusCode != http.StatusRequestTimeout || problem.Instance != "/slow" {
+		t.Fatalf("unexpected timeout response: %+v", status)
+	}
+}
@@ -2,13 +2,15 @@ package stgin
 
 import (
 	"errors"
+	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
 
-type queryDecl = map[string]string
+type queryDecl = map[string]queryDefinition
 
 // Queries is just a struct holding all the key value pairs of request's query parameters.
 // It also defines some useful receiver functions in order to ease fetching query params.
@@ -96,83 +98,180 @@ func acceptsQuery(tpe string, value string) bool {
 	}
 }
 
-func acceptsAllQueries(declarations queryDecl, qs map[string][]string) bool {
-	var accepts = true
-	for name, tpe := range declarations {
-		value := qs[name]
-		if len(value) == 0 {
-			accepts = false
+// queryDefinition holds the specification of a single query parameter declared inside a route pattern,
+// like "id:int", "page:int?", "size:int=20" or "tag:string[1,5]".
+type queryDefinition struct {
+	tpe          string
+	optional     bool
+	hasDefault   bool
+	defaultValue string
+	repeated     bool
+	minCount     int
+	maxCount     int // zero means unbounded
+}
+
+func (def queryDefinition) resolve(name string, values []string) ([]string, error) {
+	var present bool
+	for _, v := range values {
+		if v != "" {
+			present = true
 			break
 		}
-		for _, v := range value {
-			if v == "" || !acceptsQuery(tpe, v) {
-				accepts = false
-				break
-			}
+	}
+	if !present && (def.optional || def.hasDefault || len(values) == 0) {
+		switch {
+		case def.hasDefault:
+			return []string{def.defaultValue}, nil
+		case def.optional:
+			// an optional query given without a value (i.e., "?page=") is treated as absent
+			return nil, nil
+		default:
+			return nil, QueryError{query: name, details: "is required but was not provided"}
+		}
+	}
+	for _, v := range values {
+		if v == "" {
+			return nil, QueryError{query: name, details: "must not be empty"}
+		}
+		if !acceptsQuery(def.tpe, v) {
+			return nil, QueryError{query: name, details: fmt.Sprintf("expected a value of type %s, got '%s'", def.tpe, v)}
+		}
+	}
+	if def.repeated {
+		if len(values) < def.minCount {
+			return nil, QueryError{query: name, details: fmt.Sprintf("expected at least %d values, got %d", def.minCount, len(values))}
+		}
+		if def.maxCount > 0 && len(values) > def.maxCount {
+			return nil, QueryError{query: name, details: fmt.Sprintf("expected at most %d values, got %d", def.maxCount, len(values))}
 		}
+	} else if len(values) > 1 {
+		return nil, QueryError{query: name, details: fmt.Sprintf("expected a single value, got %d", len(values))}
 	}
-	return accepts
+	return values, nil
 }
 
-func getQueryDefinitionsFromPattern(pattern string) queryDecl {
+// resolveQueries checks the given query parameters against the declarations of a route,
+// and returns the queries with the declared default values filled in.
+// Optional queries given without a value are mapped to nil, meaning they must be treated as absent.
+// The first declaration (in alphabetical order) that is not satisfied is reported as a QueryError.
+func resolveQueries(declarations queryDecl, qs map[string][]string) (map[string][]string, error) {
+	resolved := make(map[string][]string, len(qs)+len(declarations))
+	for key, values := range qs {
+		resolved[key] = values
+	}
+	names := make([]string, 0, len(declarations))
+	for name := range declarations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values, err := declarations[name].resolve(name, qs[name])
+		if err != nil {
+			return nil, err
+		}
+		if values != nil {
+			resolved[name] = values
+		} else if _, given := resolved[name]; given {
+			resolved[name] = nil
+		}
+	}
+	return resolved, nil
+}
+
+// withResolved returns queries, added the resolved values (i.e., declared defaults) which are missing from it,
+// and without the ones that resolving left out (i.e., optional queries given without a value).
+func (q Queries) withResolved(resolved map[string][]string) Queries {
+	all := make(map[string][]string, len(q.All)+len(resolved))
+	for key, values := range resolved {
+		all[key] = values
+	}
+	for key, values := range q.All {
+		all[key] = values
+	}
+	for key, values := range resolved {
+		if values == nil {
+			delete(all, key)
+		}
+	}
+	return Queries{all}
+}
+
+func acceptsAllQueries(declarations queryDecl, qs map[string][]string) bool {
+	_, err := resolveQueries(declarations, qs)
+	return err == nil
+}
+
+// name[:type][[min,max]][?][=default]
+var queryDefinitionRegex = regexp.MustCompile(`^([^:\[\]?=]+)(?::([^:\[\]?=]+))?(\[(\d*)(,?)(\d*)\])?(\?)?(=(.*))?$`)
+
+func parseQueryDefinition(def string) (string, queryDefinition, error) {
+	match := queryDefinitionRegex.FindStringSubmatch(def)
+	if match == nil {
+		return "", queryDefinition{}, fmt.Errorf("could not parse '%s' as a valid query declaration", def)
+	}
+	name := match[1]
+	result := queryDefinition{
+		tpe:          "string",
+		repeated:     match[3] != "",
+		optional:     match[7] != "",
+		hasDefault:   match[8] != "",
+		defaultValue: match[9],
+	}
+	if match[2] != "" {
+		result.tpe = match[2]
+	}
+	if result.repeated {
+		result.minCount, _ = strconv.Atoi(match[4])
+		if match[5] == "" {
+			result.maxCount = result.minCount
+		} else {
+			result.maxCount, _ = strconv.Atoi(match[6])
+		}
+		if result.maxCount > 0 && result.minCount > result.maxCount {
+			return "", queryDefinition{}, fmt.Errorf("query declaration '%s' has a minimum count greater than its maximum", def)
+		}
+	}
+	if result.hasDefault && !acceptsQuery(result.tpe, result.defaultValue) {
+		return "", queryDefinition{}, fmt.Errorf("default value of query declaration '%s' is not a valid %s", def, result.tpe)
+	}
+	return name, result, nil
+}
+
+func getQueryDefinitionsFromPattern(pattern string) (queryDecl, error) {
 	defs := strings.SplitN(pattern, "&", -1)
 	qs := make(queryDecl, 10)
 	for _, def := range defs {
 		if def != "" {
-			arr := strings.SplitN(def, ":", 2)
-			name := arr[0]
-			var tpe = "string"
-			if len(arr) == 2 {
-				tpe = arr[1]
+			name, definition, err := parseQueryDefinition(def)
+			if err != nil {
+				return nil, err
 			}
-			qs[name] = tpe
+			qs[name] = definition
 		}
 	}
-	return qs
+	return qs, nil
+}
+
+func (request RequestContext) querySource() bindingSource {
+	return bindingSource{name: "query", tagKey: "qp", lookup: request.QueryParams.Get}
 }
 
 // QueryToObj receives a pointer to a struct, and tries to parse the query params into it.
+// Fields are looked up by their `qp` tag (or their name), and tag options like `qp:"page,required"` or
+// `qp:"size,default=20"` are supported. If any field cannot be bound, a BindingError describing all of them is returned.
+// The result is then validated using its `validate` tags.
 // Please read the documentations [here](https://github.com/AminMal/stgin#query-parameters) for more details.
 func (request RequestContext) QueryToObj(a any) error {
 	if reflect.TypeOf(a).Kind() != reflect.Ptr {
 		return errors.New("passed raw type instead of value pointer to QueryToObj function, please use pointers instead")
 	}
-	if reflect.ValueOf(a).Kind() != reflect.Ptr {
-		return errors.New("passed raw value instead of value pointer to QueryToObj function, please use pointers instead")
-	}
-	tpe := reflect.TypeOf(a).Elem()
-	value := reflect.ValueOf(a).Elem()
-	for i := 0; i < tpe.NumField(); i++ {
-		tpeField := tpe.Field(i)
-		if tpeField.IsExported() {
-			queryTag, _ := tpeField.Tag.Lookup("qp")
-			if queryTag == "" {
-				queryTag = tpeField.Name
-			}
-			tags := strings.SplitN(queryTag, ",", -1)
-			queryName := tags[0]
-			//otherTags := tags[1:]  WIP
-			query, found := request.QueryParams.GetOne(queryName)
-			valueField := value.Field(i)
-			if found {
-				switch valueField.Kind() {
-				case reflect.String:
-					valueField.Set(reflect.ValueOf(query))
-				case reflect.Int:
-					if queryInt, err := strconv.Atoi(query); err == nil {
-						valueField.Set(reflect.ValueOf(queryInt))
-					}
-				case reflect.Float64:
-					if queryFloat, err := strconv.ParseFloat(query, 64); err == nil {
-						valueField.Set(reflect.ValueOf(queryFloat))
-					}
-				case reflect.Float32:
-					if queryFloat, err := strconv.ParseFloat(query, 32); err == nil {
-						valueField.Set(reflect.ValueOf(queryFloat))
-					}
-				}
-			}
-		}
+	if reflect.ValueOf(a).Elem().Kind() != reflect.Struct {
+		return errors.New("passed a pointer to a non-struct value to QueryToObj function, please use struct pointers instead")
+	}
+	var fieldErrors []FieldError
+	bindStruct(reflect.ValueOf(a).Elem(), []bindingSource{request.querySource()}, "", &fieldErrors)
+	if len(fieldErrors) != 0 {
+		return BindingError{Errors: fieldErrors}
 	}
-	return nil
+	return validateWithNames(a, "qp")
 }
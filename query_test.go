@@ -1,11 +1,13 @@
 package stgin
 
 import (
+	"net"
 	"net/http"
 	"net/url"
 	"reflect"
 	"strconv"
 	"testing"
+	"time"
 )
 
 type Q struct {
@@ -20,7 +22,7 @@ func TestRequestContext_QueryToObj(t *testing.T) {
 	req := http.Request{
 		Method:     "GET",
 		URL:        uri,
-		Header:     emptyHeaders,
+		Header:     http.Header{},
 		RequestURI: "/test/queryDecl?query=search&name=John&Untagged=used&age=29",
 	}
 	rc := requestContextFromHttpRequest(&req, nil, Params{})
@@ -42,7 +44,7 @@ func mkDummyRequest(path string) *http.Request {
 	return &http.Request{
 		Method:     "GET",
 		URL:        uri,
-		Header:     emptyHeaders,
+		Header:     http.Header{},
 		RequestURI: path,
 	}
 }
@@ -56,10 +58,10 @@ func TestAcceptsAllQueries(t *testing.T) {
 	}
 	dummyRoute.correspondingRegex = regex
 	expectedQueries := queryDecl{
-		"query": "string",
-		"name":  "string",
-		"age":   "int",
-		"email": "string",
+		"query": {tpe: "string"},
+		"name":  {tpe: "string"},
+		"age":   {tpe: "int"},
+		"email": {tpe: "string"},
 	}
 	if !reflect.DeepEqual(dummyRoute.expectedQueries, expectedQueries) {
 		t.Errorf("query parser could not parse expected queryDecl in route pattern")
@@ -102,8 +104,8 @@ func TestAcceptsAllQueries2(t *testing.T) {
 	}
 	dummyRoute.correspondingRegex = regex
 	expectedQueries := queryDecl{
-		"uid":      "int",
-		"username": "string",
+		"uid":      {tpe: "int"},
+		"username": {tpe: "string"},
 	}
 	if !reflect.DeepEqual(dummyRoute.expectedQueries, expectedQueries) {
 		t.Errorf("query parser could not parse expected queryDecl in route pattern")
@@ -114,3 +116,143 @@ func TestAcceptsAllQueries2(t *testing.T) {
 		t.Fatal("WTFFFF")
 	}
 }
+
+func TestQueryDefinitionsFromPattern(t *testing.T) {
+	decl, err := getQueryDefinitionsFromPattern("page:int?&size:int=20&tag:string[1,3]&ids:int[]&flag")
+	if err != nil {
+		t.Fatalf("could not parse valid query declarations: %s", err.Error())
+	}
+	expected := queryDecl{
+		"page": {tpe: "int", optional: true},
+		"size": {tpe: "int", hasDefault: true, defaultValue: "20"},
+		"tag":  {tpe: "string", repeated: true, minCount: 1, maxCount: 3},
+		"ids":  {tpe: "int", repeated: true},
+		"flag": {tpe: "string"},
+	}
+	if !reflect.DeepEqual(decl, expected) {
+		t.Fatalf("query declarations mismatch, got: %v", decl)
+	}
+	if _, err = getQueryDefinitionsFromPattern("size:int=twenty"); err == nil {
+		t.Error("default value of wrong type got accepted")
+	}
+	if _, err = getQueryDefinitionsFromPattern("tag[3,1]"); err == nil {
+		t.Error("repeated query with min count greater than max count got accepted")
+	}
+}
+
+func TestResolveQueries(t *testing.T) {
+	decl, _ := getQueryDefinitionsFromPattern("page:int?&size:int=20&tag:string[1,3]")
+	resolved, err := resolveQueries(decl, map[string][]string{"tag": {"a", "b"}})
+	if err != nil {
+		t.Fatalf("valid queries got rejected: %s", err.Error())
+	}
+	if !reflect.DeepEqual(resolved, map[string][]string{"tag": {"a", "b"}, "size": {"20"}}) {
+		t.Fatalf("default query values were not filled in, got: %v", resolved)
+	}
+
+	_, err = resolveQueries(decl, map[string][]string{"tag": {"a", "b", "c", "d"}})
+	if queryErr, isQueryErr := err.(QueryError); !isQueryErr || queryErr.Query() != "tag" {
+		t.Fatalf("expected query error on 'tag', got: %v", err)
+	}
+	_, err = resolveQueries(decl, map[string][]string{"tag": {"a"}, "page": {"first"}})
+	if queryErr, isQueryErr := err.(QueryError); !isQueryErr || queryErr.Query() != "page" {
+		t.Fatalf("expected query error on 'page', got: %v", err)
+	}
+	_, err = resolveQueries(decl, map[string][]string{"tag": {"a"}, "page": {"1", "2"}})
+	if queryErr, isQueryErr := err.(QueryError); !isQueryErr || queryErr.Query() != "page" {
+		t.Fatalf("expected query error on repeated 'page', got: %v", err)
+	}
+	resolved, err = resolveQueries(decl, map[string][]string{"tag": {"a"}, "page": {""}})
+	if err != nil {
+		t.Fatalf("optional query without a value got rejected: %s", err.Error())
+	}
+	if page, found := resolved["page"]; !found || page != nil {
+		t.Fatalf("optional query without a value was not treated as absent, got: %v", resolved)
+	}
+	queries := Queries{map[string][]string{"tag": {"a"}, "page": {""}}}.withResolved(resolved)
+	if _, found := queries.Get("page"); found {
+		t.Fatal("optional query without a value was passed to the handler")
+	}
+}
+
+func TestInvalidQueryResponse(t *testing.T) {
+	controller := NewController("Queries", "")
+	controller.AddRoutes(GET("/items?page:int?", func(request RequestContext) Status {
+		return Ok(Empty())
+	}))
+	result := controller.executeInternal(mkDummyRequest("/items?page=first"))
+	if result.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid query, got %d", result.StatusCode)
+	}
+	result = controller.executeInternal(mkDummyRequest("/items"))
+	if result.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 for omitted optional query, got %d", result.StatusCode)
+	}
+	result = controller.executeInternal(mkDummyRequest("/items?page=1&page=2"))
+	if result.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for multiple values of a single query, got %d", result.StatusCode)
+	}
+}
+
+type pagination struct {
+	Page uint16 `qp:"page,default=1"`
+	Size *int   `qp:"size"`
+}
+
+type searchFilter struct {
+	pagination
+	Term     string        `qp:"term,required"`
+	Exact    bool          `qp:"exact"`
+	Tags     []string      `qp:"tag"`
+	Since    time.Time     `qp:"since"`
+	Within   time.Duration `qp:"within"`
+	Address  net.IP        `qp:"addr"`
+	Author   *author       `qp:"author"`
+	Ignored  string        `qp:"-"`
+}
+
+type author struct {
+	Name string `qp:"name"`
+	Age  int8   `qp:"age"`
+}
+
+func TestRequestContext_QueryToObjRichTypes(t *testing.T) {
+	rc := requestContextFromHttpRequest(
+		mkDummyRequest("/search?term=go&exact=true&tag=a&tag=b&since=2022-05-01&within=1h&addr=127.0.0.1&author.name=John&Ignored=x"),
+		nil, Params{},
+	)
+	var filter searchFilter
+	if err := rc.QueryToObj(&filter); err != nil {
+		t.Fatalf("could not bind valid queries: %s", err.Error())
+	}
+	expected := searchFilter{
+		pagination: pagination{Page: 1},
+		Term:       "go",
+		Exact:      true,
+		Tags:       []string{"a", "b"},
+		Since:      time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC),
+		Within:     time.Hour,
+		Address:    net.ParseIP("127.0.0.1"),
+		Author:     &author{Name: "John"},
+	}
+	if !reflect.DeepEqual(filter, expected) {
+		t.Fatalf("queries were not bound as expected, got: %+v", filter)
+	}
+}
+
+func TestRequestContext_QueryToObjErrors(t *testing.T) {
+	rc := requestContextFromHttpRequest(mkDummyRequest("/search?page=-1&size=ten&author.age=300"), nil, Params{})
+	var filter searchFilter
+	err := rc.QueryToObj(&filter)
+	bindingErr, isBindingErr := err.(BindingError)
+	if !isBindingErr {
+		t.Fatalf("expected binding error, got: %v", err)
+	}
+	var fields []string
+	for _, fieldErr := range bindingErr.Errors {
+		fields = append(fields, fieldErr.Field)
+	}
+	if !reflect.DeepEqual(fields, []string{"page", "size", "term", "author.age"}) {
+		t.Fatalf("binding error did not report all the invalid fields, got: %v", fields)
+	}
+}
@@ -1,8 +1,6 @@
 package stgin
 
 import (
-	"encoding/json"
-	"encoding/xml"
 	"io"
 	"mime/multipart"
 	"net/http"
@@ -112,6 +110,7 @@ type RequestBody struct {
 	underlying      io.Reader
 	underlyingBytes []byte
 	hasFilledBytes  bool
+	codecs          *CodecRegistry
 }
 
 func bodyFromBytes(bytes []byte) *RequestBody {
@@ -145,7 +144,7 @@ func requestContextFromHttpRequest(request *http.Request, writer http.ResponseWr
 	}
 	var headers http.Header
 	if request.Header == nil {
-		headers = emptyHeaders
+		headers = http.Header{}
 	} else {
 		headers = request.Header
 	}
@@ -160,6 +159,9 @@ func requestContextFromHttpRequest(request *http.Request, writer http.ResponseWr
 			if request.Body != nil {
 				body, _ = bodyFromReadCloser(request.Body)
 			}
+			if body != nil {
+				body.codecs = codecsOf(request)
+			}
 			return body
 		},
 		receivedAt:    time.Now(),
@@ -182,6 +184,9 @@ func requestContextFromHttpRequest(request *http.Request, writer http.ResponseWr
 func (body *RequestBody) fillAndGetBytes() ([]byte, *MalformedRequestContext) {
 	if body.hasFilledBytes {
 		return body.underlyingBytes, nil
+	} else if body.underlying == nil {
+		body.hasFilledBytes = true
+		return body.underlyingBytes, nil
 	} else {
 		bytes, err := io.ReadAll(body.underlying)
 		if err != nil {
@@ -193,63 +198,139 @@ func (body *RequestBody) fillAndGetBytes() ([]byte, *MalformedRequestContext) {
 	}
 }
 
-// SafeJSONInto receives a pointer to anything, and will try to parse the request bytes into it as JSON.
-// if any error occurs, it is returned immediately by the function.
-func (body *RequestBody) SafeJSONInto(a any) error {
+// registry returns the codecs of the server or controller which received the body.
+func (body *RequestBody) registry() *CodecRegistry {
+	if body.codecs == nil {
+		return DefaultCodecs
+	}
+	return body.codecs
+}
+
+// decodeInto decodes the body into a, using the codec of the media type.
+func (body *RequestBody) decodeInto(a any, tpe string, mediaType string) error {
 	bytes, err := body.fillAndGetBytes()
 	if err != nil {
 		return *err
 	}
-	if unmarshalErr := json.Unmarshal(bytes, a); unmarshalErr != nil {
+	if unmarshalErr := body.registry().lookup(mediaType).Unmarshal(bytes, a); unmarshalErr != nil {
 		return ParseError{
-			tpe:     "JSON",
+			tpe:     tpe,
 			details: unmarshalErr.Error(),
 		}
-	} else {
-		return nil
 	}
+	return nil
 }
 
-// SafeXMLInto receives a pointer to anything, and will try to parse the request bytes into it as JSON.
+// SafeJSONInto receives a pointer to anything, and will try to parse the request bytes into it as JSON,
+// using the JSON codec of the server or controller.
+// The result is then validated using its `validate` tags.
 // if any error occurs, it is returned immediately by the function.
-func (body *RequestBody) SafeXMLInto(a any) error {
-	bytes, err := body.fillAndGetBytes()
-	if err != nil {
-		return *err
+func (body *RequestBody) SafeJSONInto(a any) error {
+	if err := body.decodeInto(a, "JSON", applicationJson); err != nil {
+		return err
 	}
-	if unmarshalErr := xml.Unmarshal(bytes, a); unmarshalErr != nil {
-		return ParseError{
-			tpe:     "XML",
-			details: unmarshalErr.Error(),
-		}
-	} else {
-		return nil
+	return validateWithNames(a, "json")
+}
+
+// SafeXMLInto receives a pointer to anything, and will try to parse the request bytes into it as XML,
+// using the XML codec of the server or controller.
+// The result is then validated using its `validate` tags.
+// if any error occurs, it is returned immediately by the function.
+func (body *RequestBody) SafeXMLInto(a any) error {
+	if err := body.decodeInto(a, "XML", applicationXml); err != nil {
+		return err
 	}
+	return validateWithNames(a, "xml")
 }
 
 // JSONInto receives a pointer to anything, and will try to parse the request's JSON entity into it.
 // It panics in case any error happens.
 func (body *RequestBody) JSONInto(a any) {
-	bytes, err := body.fillAndGetBytes()
-	if err != nil {
+	if err := body.SafeJSONInto(a); err != nil {
 		panic(err)
 	}
-	if unmarshalErr := json.Unmarshal(bytes, a); unmarshalErr != nil {
-		panic(ParseError{details: err.Error(), tpe: "JSON"})
-	}
 }
 
 // XMLInto receives a pointer to anything, and will try to parse the request's XML entity into it.
 // It panics in case any error happens.
 func (body *RequestBody) XMLInto(a any) {
-	bytes, err := body.fillAndGetBytes()
-	if err != nil {
+	if err := body.SafeXMLInto(a); err != nil {
+		panic(err)
+	}
+}
+
+// SafeYAMLInto receives a pointer to anything, and will try to parse the request bytes into it as YAML,
+// using the YAML codec of the server or controller.
+// The result is then validated using its `validate` tags.
+// if any error occurs, it is returned immediately by the function.
+func (body *RequestBody) SafeYAMLInto(a any) error {
+	if err := body.decodeInto(a, "YAML", applicationYaml); err != nil {
+		return err
+	}
+	return validateWithNames(a, "yaml")
+}
+
+// SafeCSVInto receives a pointer to a [][]string or a slice of structs, and will try to parse the request bytes
+// into it as CSV, using the CSV codec of the server or controller.
+// The result is then validated using its `validate` tags.
+// if any error occurs, it is returned immediately by the function.
+func (body *RequestBody) SafeCSVInto(a any) error {
+	if err := body.decodeInto(a, "CSV", textCsv); err != nil {
+		return err
+	}
+	return validateWithNames(a, "csv")
+}
+
+// SafeMsgpackInto receives a pointer to anything, and will try to parse the request bytes into it as MessagePack,
+// using the MessagePack codec of the server or controller.
+// The result is then validated using its `validate` tags.
+// if any error occurs, it is returned immediately by the function.
+func (body *RequestBody) SafeMsgpackInto(a any) error {
+	if err := body.decodeInto(a, "MessagePack", applicationMsgpack); err != nil {
+		return err
+	}
+	return validateWithNames(a, "msgpack")
+}
+
+// SafeFormInto receives a pointer to a struct or a map, and will try to parse the request bytes into it
+// as a url-encoded form, using the form codec of the server or controller.
+// The result is then validated using its `validate` tags.
+// if any error occurs, it is returned immediately by the function.
+func (body *RequestBody) SafeFormInto(a any) error {
+	if err := body.decodeInto(a, "form", formUrlEncoded); err != nil {
+		return err
+	}
+	return validateWithNames(a, "form")
+}
+
+// YAMLInto receives a pointer to anything, and will try to parse the request's YAML entity into it.
+// It panics in case any error happens.
+func (body *RequestBody) YAMLInto(a any) {
+	if err := body.SafeYAMLInto(a); err != nil {
+		panic(err)
+	}
+}
+
+// CSVInto receives a pointer to a [][]string or a slice of structs, and will try to parse the request's CSV entity into it.
+// It panics in case any error happens.
+func (body *RequestBody) CSVInto(a any) {
+	if err := body.SafeCSVInto(a); err != nil {
 		panic(err)
 	}
-	if unmarshalErr := xml.Unmarshal(bytes, a); unmarshalErr != nil {
-		panic(ParseError{
-			tpe:     "XML",
-			details: err.Error(),
-		})
+}
+
+// MsgpackInto receives a pointer to anything, and will try to parse the request's MessagePack entity into it.
+// It panics in case any error happens.
+func (body *RequestBody) MsgpackInto(a any) {
+	if err := body.SafeMsgpackInto(a); err != nil {
+		panic(err)
+	}
+}
+
+// FormInto receives a pointer to a struct or a map, and will try to parse the request's url-encoded form into it.
+// It panics in case any error happens.
+func (body *RequestBody) FormInto(a any) {
+	if err := body.SafeFormInto(a); err != nil {
+		panic(err)
 	}
 }
@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -14,7 +17,7 @@ func TestRequestContext_GetQuery(t *testing.T) {
 		Url:         "/test",
 		QueryParams: Queries{map[string][]string{"q": {"search"}, "date": {"2022-19:D"}}},
 		PathParams:  PathParams{Params{}},
-		Headers:     emptyHeaders,
+		Headers:     http.Header{},
 		receivedAt:  time.Now(),
 		Method:      "GET",
 	}
@@ -39,7 +42,7 @@ func TestRequestContext_GetPathParam(t *testing.T) {
 	req := http.Request{
 		Method:     "GET",
 		URL:        uri,
-		Header:     emptyHeaders,
+		Header:     http.Header{},
 		RequestURI: "/test/JohnDoe/14",
 	}
 	accepts, pathParams := route.acceptsAndPathParams(&req)
@@ -120,3 +123,139 @@ func TestRequestContext_MustGetPathParam(t *testing.T) {
 		t.Fatal("path params parse failure")
 	}
 }
+
+type updateUserRequest struct {
+	Id      int    `path:"id"`
+	Notify  bool   `qp:"notify"`
+	Tenant  string `header:"X-Tenant,required"`
+	Session string `cookie:"sid"`
+	Name    string `json:"name" xml:"name"`
+	Age     int    `json:"age" xml:"age"`
+}
+
+func TestRequestContext_SafeBind(t *testing.T) {
+	req := httptest.NewRequest("PUT", "/users/12?notify=true", strings.NewReader(`{"name":"John","age":22}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("X-Tenant", "acme")
+	req.AddCookie(&http.Cookie{Name: "sid", Value: "abc"})
+	rc := requestContextFromHttpRequest(req, nil, Params{"id": "12"})
+	var result updateUserRequest
+	if err := rc.SafeBind(&result); err != nil {
+		t.Fatalf("could not bind valid request: %s", err.Error())
+	}
+	expected := updateUserRequest{Id: 12, Notify: true, Tenant: "acme", Session: "abc", Name: "John", Age: 22}
+	if result != expected {
+		t.Fatalf("request was not bound as expected, got: %+v", result)
+	}
+}
+
+type bindingFilter struct {
+	Owner string `header:"X-Owner"`
+	Term  string `json:"term"`
+}
+
+type forgeableRequest struct {
+	Session string `cookie:"sid"`
+	Admin   *bool  `header:"X-Admin"`
+	Name    string `json:"name"`
+	Filter  *bindingFilter
+	Ignored string `qp:"-" json:"ignored"`
+}
+
+func TestRequestContext_SafeBindBodyCannotSetBoundFields(t *testing.T) {
+	body := `{"Session":"forged","Admin":true,"name":"John","Filter":{"Owner":"forged","term":"go"},"ignored":"from body"}`
+	req := httptest.NewRequest("POST", "/search", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rc := requestContextFromHttpRequest(req, nil, Params{})
+	result := forgeableRequest{Session: "preset"}
+	if err := rc.SafeBind(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Session != "preset" || result.Admin != nil || result.Name != "John" || result.Ignored != "from body" {
+		t.Fatalf("the body set fields bound from the other parts of the request, got %+v", result)
+	}
+	if result.Filter == nil || result.Filter.Owner != "" || result.Filter.Term != "go" {
+		t.Fatalf("the body set nested fields bound from the other parts of the request, got %+v", result.Filter)
+	}
+	preset := &bindingFilter{Owner: "preset"}
+	result = forgeableRequest{Filter: preset}
+	req = httptest.NewRequest("POST", "/search", strings.NewReader(body))
+	if err := requestContextFromHttpRequest(req, nil, Params{}).SafeBind(&result); err != nil {
+		t.Fatal(err)
+	}
+	if preset.Owner != "preset" || result.Filter.Owner != "preset" || result.Filter.Term != "go" {
+		t.Fatalf("the body wrote through a preset nested struct, got %+v and %+v", preset, result.Filter)
+	}
+
+	req = httptest.NewRequest("POST", "/search", strings.NewReader("name: John"))
+	req.Header.Set("Content-Type", "text/x-unknown")
+	err := requestContextFromHttpRequest(req, nil, Params{}).SafeBind(&result)
+	if coder, isStatusCoder := err.(StatusCoder); !isStatusCoder || coder.StatusCode() != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415 for unsupported content types, got %v", err)
+	}
+	if status := errorAction(rc, err); status.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected the default error handler to respond with 415, got %d", status.StatusCode)
+	}
+}
+
+type signupForm struct {
+	Email string `form:"email,required"`
+	Age   uint8  `form:"age"`
+}
+
+func TestRequestContext_SafeBindErrors(t *testing.T) {
+	req := httptest.NewRequest("POST", "/signup", strings.NewReader("age=old"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rc := requestContextFromHttpRequest(req, nil, Params{})
+	var form signupForm
+	err := rc.SafeBind(&form)
+	bindingErr, isBindingErr := err.(BindingError)
+	if !isBindingErr {
+		t.Fatalf("expected binding error, got: %v", err)
+	}
+	expected := []FieldError{
+		{Field: "email", Source: "form", Message: "is required"},
+		{Field: "age", Source: "form", Message: "expected an unsigned integer of 8 bits, got 'old'"},
+	}
+	if !reflect.DeepEqual(bindingErr.Errors, expected) {
+		t.Fatalf("binding errors mismatch, got: %+v", bindingErr.Errors)
+	}
+}
+
+func TestRequestBody_FormatDecoders(t *testing.T) {
+	type item struct {
+		Name string `yaml:"name" csv:"name" msgpack:"name" form:"name" validate:"required"`
+		Age  int    `yaml:"age" csv:"age" msgpack:"age" form:"age"`
+	}
+	msgpackBytes, _ := MsgpackCodec{}.Marshal(item{Name: "John", Age: 22})
+	decoders := map[string]struct {
+		content   string
+		malformed string
+		decode    func(body *RequestBody, a any) error
+	}{
+		"YAML":        {"name: John\nage: 22\n", "name: [", (*RequestBody).SafeYAMLInto},
+		"MessagePack": {string(msgpackBytes), "\x92", (*RequestBody).SafeMsgpackInto},
+		"form":        {"name=John&age=22", "name=%zz", (*RequestBody).SafeFormInto},
+	}
+	for name, decoder := range decoders {
+		var result item
+		if err := decoder.decode(bodyFromBytes([]byte(decoder.content)), &result); err != nil || result != (item{Name: "John", Age: 22}) {
+			t.Errorf("%s was not decoded, got %+v (%v)", name, result, err)
+		}
+		if _, isParseError := decoder.decode(bodyFromBytes([]byte(decoder.malformed)), &result).(ParseError); !isParseError {
+			t.Errorf("expected a ParseError decoding malformed %s", name)
+		}
+	}
+	var items []item
+	if err := bodyFromBytes([]byte("name,age\nJohn,22\n,3\n")).SafeCSVInto(&items); err == nil {
+		t.Fatal("csv rows were not validated")
+	} else if validationErr, isValidationErr := err.(ValidationError); !isValidationErr || validationErr.Errors[0].Field != "[1].name" {
+		t.Fatalf("expected a validation error for the second row, got %v", err)
+	}
+	defer func() {
+		if _, isParseError := recover().(ParseError); !isParseError {
+			t.Fatal("YAMLInto did not panic with a ParseError")
+		}
+	}()
+	bodyFromBytes([]byte("name: [")).YAMLInto(&items)
+}
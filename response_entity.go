@@ -1,12 +1,11 @@
 package stgin
 
 import (
-	"encoding/json"
-	"encoding/xml"
-	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
 	"os"
-	"regexp"
-	"strings"
+	"time"
 )
 
 const (
@@ -15,8 +14,6 @@ const (
 	plainText       = "text/plain"
 )
 
-var getFileFormatRegex = regexp.MustCompile(".*\\.(.+)$")
-
 /*
 	ResponseEntity is an interface representing anything that can be sent through http response body.
 	Structs implementing ResponseEntity must have a content type (which is written directly in the response),
@@ -40,8 +37,13 @@ type ResponseEntity interface {
 	Bytes() ([]byte, error)
 }
 
-func marshall(re ResponseEntity) (bytes []byte, contentType string, err error) {
-	bytes, err = re.Bytes()
+// marshall encodes the entity using the codecs, if it's encoded by codecs (see encodedEntity).
+func marshall(re ResponseEntity, codecs *CodecRegistry) (bytes []byte, contentType string, err error) {
+	if encoded, isEncoded := re.(encodedEntity); isEncoded {
+		bytes, err = encoded.encode(codecs)
+	} else {
+		bytes, err = re.Bytes()
+	}
 	contentType = re.ContentType()
 	return
 }
@@ -56,6 +58,41 @@ func (e emptyEntity) Bytes() ([]byte, error) {
 	return []byte{}, nil
 }
 
+type bytesEntity struct {
+	contentType string
+	bytes       []byte
+}
+
+func (b bytesEntity) ContentType() string {
+	return b.contentType
+}
+
+func (b bytesEntity) Bytes() ([]byte, error) {
+	return b.bytes, nil
+}
+
+// servedEntity is implemented by entities which write themselves into the response (like static files),
+// so that they can make use of the request (i.e., conditional and range requests) without loading the whole content.
+// Headers and cookies of the status are written before, and the entity is responsible for writing the given status code,
+// which it may override (i.e., 206 partial content or 304 not modified).
+// Entities which fail before writing anything can return the error, which is handed to the server's error handler.
+type servedEntity interface {
+	serve(writer http.ResponseWriter, request *http.Request, statusCode int) error
+}
+
+// serveContent writes the content using http.ServeContent for 200 responses, which handles conditional and range requests.
+// Other status codes (i.e., a file served as a 404 page) are written as is, along with the whole content.
+func serveContent(writer http.ResponseWriter, request *http.Request, statusCode int, name string, modTime time.Time, content io.ReadSeeker) {
+	if statusCode == http.StatusOK {
+		http.ServeContent(writer, request, name, modTime, content)
+		return
+	}
+	writer.WriteHeader(statusCode)
+	if request.Method != http.MethodHead {
+		_, _ = io.Copy(writer, content)
+	}
+}
+
 type jsonEntity struct {
 	obj any
 }
@@ -65,7 +102,11 @@ func (j jsonEntity) ContentType() string {
 }
 
 func (j jsonEntity) Bytes() ([]byte, error) {
-	return json.Marshal(j.obj)
+	return j.encode(DefaultCodecs)
+}
+
+func (j jsonEntity) encode(codecs *CodecRegistry) ([]byte, error) {
+	return codecs.lookup(applicationJson).Marshal(j.obj)
 }
 
 type xmlEntity struct {
@@ -77,7 +118,29 @@ func (xe xmlEntity) ContentType() string {
 }
 
 func (xe xmlEntity) Bytes() ([]byte, error) {
-	return xml.Marshal(xe.obj)
+	return xe.encode(DefaultCodecs)
+}
+
+func (xe xmlEntity) encode(codecs *CodecRegistry) ([]byte, error) {
+	return codecs.lookup(applicationXml).Marshal(xe.obj)
+}
+
+// codecEntity is encoded using the codec of its media type.
+type codecEntity struct {
+	mediaType string
+	obj       any
+}
+
+func (ce codecEntity) ContentType() string {
+	return ce.mediaType
+}
+
+func (ce codecEntity) Bytes() ([]byte, error) {
+	return ce.encode(DefaultCodecs)
+}
+
+func (ce codecEntity) encode(codecs *CodecRegistry) ([]byte, error) {
+	return codecs.lookup(ce.mediaType).Marshal(ce.obj)
 }
 
 type textEntity struct {
@@ -93,53 +156,64 @@ func (t textEntity) Bytes() ([]byte, error) {
 }
 
 type fileContent struct {
+	fsys fs.FS // nil means the os file system
 	path string
 }
 
+// ContentType is detected from the file's extension, or by sniffing the file's content if the extension is unknown.
 func (f fileContent) ContentType() string {
-	isValidPath := getFileFormatRegex.MatchString(f.path)
-	if !isValidPath {
-		panic(fmt.Sprintf("not a valid filepath: '%s'", f.path))
-	}
-	fileType := getFileFormatRegex.FindStringSubmatch(f.path)[1]
-	switch strings.ToLower(fileType) {
-	case "html":
-		return "text/html"
-	case "htm":
-		return "text/html"
-	case "jpeg":
-		return "image/jpeg"
-	case "jpg":
-		return "image/jpeg"
-	case "pdf":
-		return "application/pdf"
-	case "png":
-		return "image/png"
-	case "rar":
-		return "application/vnd.rar"
-	case "mp3":
-		return "audio/mpeg"
-	case "mp4":
-		return "video/mp4"
-	case "mpeg":
-		return "video/mpeg"
-	case "ppt":
-		return "application/vnd.ms-powerpoint"
-	case "svg":
-		return "image/svg+xml"
-	case "wav":
-		return "audio/wav"
-	case "txt":
-		return "text/plain"
-	default:
-		return "text/plain"
-	}
+	return detectContentType(f.path, f.open)
 }
 
 func (f fileContent) Bytes() ([]byte, error) {
+	if f.fsys != nil {
+		return fs.ReadFile(f.fsys, f.path)
+	}
 	return os.ReadFile(f.path)
 }
 
+func (f fileContent) open() (fs.File, error) {
+	if f.fsys != nil {
+		return f.fsys.Open(f.path)
+	}
+	return os.Open(f.path)
+}
+
+// serve streams the file into the response, so that *os.File contents can be sent using sendfile.
+func (f fileContent) serve(writer http.ResponseWriter, request *http.Request, statusCode int) error {
+	file, err := f.open()
+	if err != nil {
+		_ = stginLogger.ErrorF("error reading file '%s': %s", f.path, err.Error())
+		if os.IsNotExist(err) {
+			writeProblem(writer, request, http.StatusNotFound, "file not found")
+		} else {
+			writeProblem(writer, request, http.StatusInternalServerError, "internal server error")
+		}
+		return nil
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	var content io.ReadSeeker
+	if err == nil {
+		content, err = readSeeker(file)
+	}
+	if err != nil {
+		_ = stginLogger.ErrorF("error reading file '%s': %s", f.path, err.Error())
+		writeProblem(writer, request, http.StatusInternalServerError, "internal server error")
+		return nil
+	}
+	if writer.Header().Get(contentTypeKey) == "" {
+		writer.Header().Set(contentTypeKey, f.ContentType())
+	}
+	if writer.Header().Get("ETag") == "" {
+		if etag := weakETag(info, f.open); etag != "" {
+			writer.Header().Set("ETag", etag)
+		}
+	}
+	serveContent(writer, request, statusCode, info.Name(), info.ModTime(), content)
+	return nil
+}
+
 // Json is a shortcut to convert any object into a JSON ResponseEntity.
 func Json(a any) ResponseEntity {
 	return jsonEntity{obj: a}
@@ -150,6 +224,27 @@ func Xml(a any) ResponseEntity {
 	return xmlEntity{obj: a}
 }
 
+// Yaml is a shortcut to convert any object into a YAML ResponseEntity. Decoding YAML bodies only supports a subset of
+// YAML, see YAMLCodec.
+func Yaml(a any) ResponseEntity {
+	return codecEntity{mediaType: applicationYaml, obj: a}
+}
+
+// Csv is a shortcut to convert a [][]string or a slice of structs into a CSV ResponseEntity, see CSVCodec.
+func Csv(a any) ResponseEntity {
+	return codecEntity{mediaType: textCsv, obj: a}
+}
+
+// Msgpack is a shortcut to convert any object into a MessagePack ResponseEntity, see MsgpackCodec.
+func Msgpack(a any) ResponseEntity {
+	return codecEntity{mediaType: applicationMsgpack, obj: a}
+}
+
+// Form is a shortcut to convert a struct or a map into a url-encoded form ResponseEntity.
+func Form(a any) ResponseEntity {
+	return codecEntity{mediaType: formUrlEncoded, obj: a}
+}
+
 // Text is a shortcut to convert any object into a text ResponseEntity.
 func Text(text string) ResponseEntity {
 	return textEntity{obj: text}
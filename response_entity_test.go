@@ -3,7 +3,12 @@ package stgin
 import (
 	"encoding/json"
 	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -20,8 +25,8 @@ func TestText(t *testing.T) {
 }
 
 type testStruct struct {
-	Message		string `json:"message"`
-	Status 		bool `json:"status"`
+	Message string `json:"message"`
+	Status  bool   `json:"status"`
 }
 
 func TestJson(t *testing.T) {
@@ -54,4 +59,118 @@ func TestXml(t *testing.T) {
 	if !reflect.DeepEqual(xmlBytes, responseBytes) {
 		t.Fatal("xml bytes and response entity bytes are not equal")
 	}
-}
\ No newline at end of file
+}
+
+func TestFileRangeAndConditionalRequests(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.txt")
+	_ = os.WriteFile(path, []byte("0123456789"), 0o644)
+	controller := NewController("Files", "")
+	controller.AddRoutes(
+		GET("/report", func(RequestContext) Status { return File(path) }),
+		GET("/missing", func(RequestContext) Status { return File(path + ".missing") }),
+	)
+	handler := (&Server{Controllers: []*Controller{controller}, notFoundAction: notFoundDefaultAction}).HttpHandler()
+	serve := func(target string, headers map[string]string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest("GET", target, nil)
+		for key, value := range headers {
+			request.Header.Set(key, value)
+		}
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	response := serve("/report", nil)
+	if response.Code != http.StatusOK || response.Body.String() != "0123456789" || response.Header().Get("Last-Modified") == "" {
+		t.Fatalf("file was not served as expected: %d %s %v", response.Code, response.Body.String(), response.Header())
+	}
+	if response = serve("/report", map[string]string{"Range": "bytes=2-4"}); response.Code != http.StatusPartialContent || response.Body.String() != "234" {
+		t.Fatalf("single range was not served: %d %s", response.Code, response.Body.String())
+	}
+	response = serve("/report", map[string]string{"Range": "bytes=0-1,8-9"})
+	if response.Code != http.StatusPartialContent || !strings.HasPrefix(response.Header().Get("Content-Type"), "multipart/byteranges") {
+		t.Fatalf("multiple ranges were not served: %d %v", response.Code, response.Header())
+	}
+	lastModified := serve("/report", nil).Header().Get("Last-Modified")
+	if response = serve("/report", map[string]string{"If-Modified-Since": lastModified}); response.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for unmodified file, got %d", response.Code)
+	}
+	if response = serve("/missing", nil); response.Code != http.StatusNotFound || response.Header().Get("Content-Type") != applicationProblemJson {
+		t.Fatalf("expected a 404 problem for missing file, got %d %v", response.Code, response.Header())
+	}
+}
+
+func TestContentDisposition(t *testing.T) {
+	cases := map[string]string{
+		"report.pdf":        `attachment; filename="report.pdf"; filename*=UTF-8''report.pdf`,
+		"my \"report\".csv": `attachment; filename="my _report_.csv"; filename*=UTF-8''my%20%22report%22.csv`,
+		"گزارش 1.txt":       `attachment; filename="_____ 1.txt"; filename*=UTF-8''%DA%AF%D8%B2%D8%A7%D8%B1%D8%B4%201.txt`,
+		"":                  `attachment`,
+	}
+	for filename, expected := range cases {
+		if disposition := contentDisposition("attachment", filename); disposition != expected {
+			t.Errorf("unexpected content disposition for %q: %s", filename, disposition)
+		}
+	}
+}
+
+func TestAttachment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.bin")
+	_ = os.WriteFile(path, []byte("0123456789"), 0o644)
+	controller := NewController("Downloads", "")
+	controller.AddRoutes(
+		GET("/report", func(RequestContext) Status { return Ok(Attachment(path, "report 2022.bin")) }),
+		GET("/preview", func(RequestContext) Status { return Ok(Attachment(path, "preview.bin").Inline()) }),
+		GET("/missing", func(RequestContext) Status { return Ok(Attachment(path+".missing", "missing.bin")) }),
+		GET("/export", func(RequestContext) Status {
+			return Ok(AttachmentFrom(strings.NewReader("id,name\n1,john\n"), "users.csv", "text/csv"))
+		}),
+	)
+	handler := (&Server{Controllers: []*Controller{controller}, notFoundAction: notFoundDefaultAction}).HttpHandler()
+	serve := func(target string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", target, nil))
+		return recorder
+	}
+
+	response := serve("/report")
+	if response.Body.String() != "0123456789" || response.Header().Get("Content-Disposition") != `attachment; filename="report 2022.bin"; filename*=UTF-8''report%202022.bin` {
+		t.Fatalf("file was not served as an attachment: %s %v", response.Body.String(), response.Header())
+	}
+	if response = serve("/preview"); !strings.HasPrefix(response.Header().Get("Content-Disposition"), "inline;") {
+		t.Fatalf("inline attachment got: %s", response.Header().Get("Content-Disposition"))
+	}
+	response = serve("/missing")
+	if response.Code != http.StatusNotFound || response.Header().Get("Content-Disposition") != "" ||
+		response.Header().Get("Content-Type") != applicationProblemJson || !strings.Contains(response.Body.String(), `"instance":"/missing"`) {
+		t.Fatalf("missing attachment was not responded with 404, got: %d %v", response.Code, response.Header())
+	}
+	response = serve("/export")
+	if response.Body.String() != "id,name\n1,john\n" || response.Header().Get("Content-Type") != "text/csv" ||
+		!strings.HasPrefix(response.Header().Get("Content-Disposition"), `attachment; filename="users.csv"`) {
+		t.Fatalf("generated content was not served as an attachment: %s %v", response.Body.String(), response.Header())
+	}
+}
+
+func TestFormatEntities(t *testing.T) {
+	obj := map[string]any{"message": "hi", "ids": []int{1, 2}}
+	entities := []struct {
+		entity      ResponseEntity
+		contentType string
+		content     string
+	}{
+		{Yaml(obj), "application/yaml", "ids:\n  - 1\n  - 2\nmessage: hi\n"},
+		{Msgpack(obj), "application/msgpack", "\x82\xa3ids\x92\x01\x02\xa7message\xa2hi"},
+		{Form(obj), "application/x-www-form-urlencoded", "ids=1&ids=2&message=hi"},
+		{Csv([][]string{{"a", "b"}}), "text/csv", "a,b\n"},
+	}
+	for _, expected := range entities {
+		content, err := expected.entity.Bytes()
+		if err != nil || expected.entity.ContentType() != expected.contentType || string(content) != expected.content {
+			t.Errorf("expected %s %q, got %s %q (%v)", expected.contentType, expected.content, expected.entity.ContentType(), content, err)
+		}
+	}
+	if _, err := Csv(obj).Bytes(); err == nil {
+		t.Fatal("maps should not be encoded as csv")
+	}
+}
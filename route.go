@@ -1,6 +1,7 @@
 package stgin
 
 import (
+	"io/fs"
 	"net/http"
 	"regexp"
 	"strings"
@@ -17,22 +18,40 @@ type Route struct {
 	Action             API
 	correspondingRegex *regexp.Regexp
 	controller         *Controller
-	dir                string
+	static             *staticDir
 	expectedQueries    queryDecl
 }
 
-func (route Route) isStaticDir() bool { return route.dir != "" }
+func (route Route) isStaticDir() bool { return route.static != nil }
+
+// staticPrefix returns the literal prefix of a static directory route, which is stripped from the request paths.
+func (route Route) staticPrefix() string { return strings.TrimSuffix(route.Path, ".*") }
 
 func (route Route) acceptsAndPathParams(request *http.Request) (bool, Params) {
-	var ok bool
-	var params Params
-	if request.Method == route.Method {
-		params, ok = matchAndExtractPathParams(&route, request.URL.Path)
+	if request.Method == route.Method || (route.isStaticDir() && request.Method == http.MethodHead) {
+		return route.matchesPath(request.URL.Path)
 	}
+	return false, nil
+}
 
+// matchesPath reports whether the route would handle the given path, regardless of the request method.
+func (route Route) matchesPath(path string) (bool, Params) {
+	params, ok := matchAndExtractPathParams(&route, path)
+	// the bare prefix of static directories (i.e., "/static") is redirected to the directory
+	if !ok && route.isStaticDir() && path+"/" == route.staticPrefix() {
+		ok = true
+	}
 	return ok, params
 }
 
+// methods returns the request methods which the route answers.
+func (route Route) methods() []string {
+	if route.isStaticDir() && route.Method != http.MethodHead {
+		return []string{route.Method, http.MethodHead}
+	}
+	return []string{route.Method}
+}
+
 func getRoutePatternRegexOrPanic(pattern string) *regexp.Regexp {
 	regex, err := getPatternCorrespondingRegex(pattern)
 	if err != nil {
@@ -47,11 +66,16 @@ func mkRoute(pattern string, api API, method string) Route {
 		panic("cannot use nil as an API action")
 	}
 	path, queryDefs := splitBy(pattern, "?")
+	expectedQueries, err := getQueryDefinitionsFromPattern(queryDefs)
+	if err != nil {
+		printStacktrace("")
+		panic(err)
+	}
 	return Route{
 		Path:            path,
 		Method:          method,
 		Action:          api,
-		expectedQueries: getQueryDefinitionsFromPattern(queryDefs),
+		expectedQueries: expectedQueries,
 	}
 }
 
@@ -93,12 +117,33 @@ func Prefix(path string) string {
 
 // StaticDir can be used to server static directories.
 // It's better to use StaticDir inside the server itself, or to have a dedicated controller for static directories you
-// would want to serve.
+// would want to serve. Directory contents are not listed, use StaticDirWithConfig for more control.
 func StaticDir(pattern string, dir string) Route {
+	return StaticDirWithConfig(pattern, dir, StaticConfig{})
+}
+
+// StaticDirWithConfig serves the given static directory just like StaticDir, with the specifications in config
+// (i.e., index files, directory listing, single page application fallback and cache policies).
+func StaticDirWithConfig(pattern string, dir string, config StaticConfig) Route {
+	return staticRoute(pattern, staticDirOnDisk(dir, config))
+}
+
+// StaticFS serves the files inside the given file system (like an embed.FS) just like StaticDir.
+// Use fs.Sub to serve a sub-directory of the file system.
+func StaticFS(pattern string, fsys fs.FS) Route {
+	return StaticFSWithConfig(pattern, fsys, StaticConfig{})
+}
+
+// StaticFSWithConfig serves the files inside the given file system, with the specifications in config.
+func StaticFSWithConfig(pattern string, fsys fs.FS, config StaticConfig) Route {
+	return staticRoute(pattern, staticDirOnFS(fsys, config))
+}
+
+func staticRoute(pattern string, static *staticDir) Route {
 	return Route{
-		Path:   normalizePath("/" + pattern + "/"),
+		Path:   Prefix(pattern),
 		Method: "GET",
-		dir:    dir,
+		static: static,
 	}
 }
 
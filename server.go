@@ -1,13 +1,16 @@
 package stgin
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/AminMal/slogger/colored"
 	"net/http"
+	"sort"
 	"time"
 	"mime/multipart"
 	"strings"
+	"sync"
 )
 
 var defaultController *Controller = NewController("Server", "")
@@ -23,6 +26,11 @@ type Server struct {
 	notFoundAction    API
 	errorAction       ErrorHandler
 	interrupts        []Interrupt
+	codecs            *CodecRegistry
+	compression       *CompressionConfig
+	hubs              []*Hub
+	lifecycle         sync.Mutex
+	httpServer        *http.Server
 }
 
 // Register appends given controllers to the server.
@@ -80,6 +88,18 @@ func (server *Server) SetErrorHandler(action ErrorHandler) {
 	server.errorAction = action
 }
 
+// SetCodecs sets the codecs used to decode the request bodies and encode the response entities of the server,
+// controllers which have codecs of their own use them instead.
+func (server *Server) SetCodecs(codecs *CodecRegistry) {
+	server.codecs = codecs
+}
+
+// EnableCompression compresses the responses of the server based on the Accept-Encoding header of the requests,
+// controllers which have compression enabled use their own configuration instead.
+func (server *Server) EnableCompression(config CompressionConfig) {
+	server.compression = &config
+}
+
 // SetTimeout registers a timeout interrupt to the server
 func (server *Server) SetTimeout(dur time.Duration) {
 	server.RegisterInterrupts(TimeoutInterrupt(dur))
@@ -111,19 +131,18 @@ func translate(
 	apiListeners []APIListener,
 	recovery ErrorHandler,
 	pathParams Params,
+	queries map[string][]string,
 	interrupts []Interrupt,
+	codecs *CodecRegistry,
 ) http.HandlerFunc {
 	panicChannel := make(chan interface{}, 1)
 	successfulResultChannel := make(chan *Status, 1)
 	interruptChannel := make(chan *Status, 1)
 
 	return func(writer http.ResponseWriter, request *http.Request) {
-		queries := make(map[string][]string, 10)
-		for key, value := range request.URL.Query() {
-			queries[key] = value
-		}
-
+		request = withCodecs(request, codecs)
 		rc := requestContextFromHttpRequest(request, writer, pathParams)
+		rc.QueryParams = Queries{}.withResolved(queries)
 
 		for _, requestListener := range requestListeners {
 			rc = requestListener(rc)
@@ -143,16 +162,22 @@ func translate(
 
 		select {
 		case interrupt := <-interruptChannel:
-			result := *interrupt
-			interrupt.complete(request, writer)
+			result, aborted := completeStatus(interrupt, rc, recovery, request, writer)
 			for _, apiListener := range apiListeners {
 				apiListener(rc, result)
 			}
+			if aborted {
+				panic(http.ErrAbortHandler)
+			}
 		case success := <-successfulResultChannel:
-			success.complete(request, writer)
+			result, aborted := completeStatus(success, rc, recovery, request, writer)
 
 			for _, apiListener := range apiListeners {
-				go apiListener(rc, *success)
+				go apiListener(rc, result)
+			}
+			if aborted {
+				// closes the connection, so that the client knows the response is incomplete
+				panic(http.ErrAbortHandler)
 			}
 
 		case err := <-panicChannel:
@@ -160,12 +185,45 @@ func translate(
 				panic(err)
 			} else {
 				status := recovery(rc, err)
-				write(status, writer)
+				write(status, writer, codecs)
 			}
 		}
 	}
 }
 
+// completeStatus writes the status into the response. If the entity fails before anything is written,
+// the error is handed to recovery just like panics. Otherwise, the response cannot be changed anymore,
+// so the error is logged, and the response is reported as aborted.
+func completeStatus(
+	status *Status,
+	rc RequestContext,
+	recovery ErrorHandler,
+	request *http.Request,
+	writer http.ResponseWriter,
+) (result Status, aborted bool) {
+	err := status.complete(request, writer)
+	if err == nil {
+		return *status, false
+	}
+	var abortedErr abortedResponse
+	if errors.As(err, &abortedErr) {
+		_ = stginLogger.ErrorF("%s -> %s\t\t| response aborted:\n\t%s%s%s",
+			request.Method, request.URL.Path, colored.RED, abortedErr.err.Error(), colored.ResetPrevColor,
+		)
+		return *status, true
+	}
+	if recovery == nil {
+		panic(err)
+	}
+	// nothing is written yet, so the headers of the failed status are dropped
+	for key := range writer.Header() {
+		writer.Header().Del(key)
+	}
+	result = recovery(rc, err)
+	write(result, writer, codecsOf(request))
+	return result, false
+}
+
 // WatchAPIs is the default request and response logger for stgin.
 // It logs the input request and the output response into the console.
 func WatchAPIs(request RequestContext, status Status) {
@@ -180,39 +238,44 @@ func WatchAPIs(request RequestContext, status Status) {
 	_ = stginLogger.InfoF("%s -> %s\t\t| %v | %v", request.Method, request.Url, statusString, difference)
 }
 
-type generalFailureMessage struct {
-	StatusCode int    `json:"status_code"`
-	Path       string `json:"path"`
-	Message    string `json:"message"`
-	Method     string `json:"method"`
+// invalidFieldsResponse is the 400 problem of binding and validation failures, which lists the fields in "errors".
+func invalidFieldsResponse(request RequestContext, message string, errors []FieldError) Status {
+	return problemResponse(request, NewProblem(http.StatusBadRequest, message).With("errors", errors))
 }
 
 var notFoundDefaultAction API = func(request RequestContext) Status {
-	return NotFound(Json(&generalFailureMessage{
-		StatusCode: http.StatusNotFound,
-		Path:       request.Url,
-		Message:    "route not found",
-		Method:     request.Method,
-	}))
+	return failureResponse(request, http.StatusNotFound, "route not found")
+}
+
+func invalidQueryAction(err error) API {
+	return func(request RequestContext) Status {
+		return failureResponse(request, http.StatusBadRequest, err.Error())
+	}
+}
+
+func methodNotAllowedAction(allowed []string) API {
+	return func(request RequestContext) Status {
+		detail := fmt.Sprintf("method %s is not allowed, use one of %s", request.Method, strings.Join(allowed, ", "))
+		return failureResponse(request, http.StatusMethodNotAllowed, detail).WithHeader("Allow", strings.Join(allowed, ", "))
+	}
 }
 
 var errorAction ErrorHandler = func(request RequestContext, err any) Status {
 	printStacktrace(fmt.Sprintf("recovering following error: %v%v%v", colored.RED, fmt.Sprint(err), colored.ResetPrevColor))
+	if bindingErr, isBindingError := err.(BindingError); isBindingError {
+		return invalidFieldsResponse(request, "could not bind request values", bindingErr.Errors)
+	}
+	if validationErr, isValidationError := err.(ValidationError); isValidationError {
+		return invalidFieldsResponse(request, "request validation failed", validationErr.Errors)
+	}
 	if parseErr, isParseError := err.(ParseError); isParseError {
-		return BadRequest(Json(&generalFailureMessage{
-			StatusCode: 400,
-			Path:       request.Url,
-			Message:    parseErr.Error(),
-			Method:     request.Method,
-		}))
+		return failureResponse(request, http.StatusBadRequest, parseErr.Error())
+	}
+	if coder, isStatusCoder := err.(StatusCoder); isStatusCoder {
+		return failureResponse(request, coder.StatusCode(), fmt.Sprint(err))
 	}
 
-	return InternalServerError(Json(&generalFailureMessage{
-		StatusCode: 500,
-		Path:       request.Url,
-		Message:    "internal server error",
-		Method:     request.Method,
-	}))
+	return failureResponse(request, http.StatusInternalServerError, "internal server error")
 }
 
 type apiHandler struct {
@@ -220,48 +283,109 @@ type apiHandler struct {
 	server           *Server
 }
 
+func (handler apiHandler) serve(
+	route Route,
+	api API,
+	pathParams Params,
+	queries map[string][]string,
+	writer http.ResponseWriter,
+	request *http.Request,
+) {
+	requestListeners := append(handler.server.requestListeners, route.controller.requestListeners...)
+	responseListeners := append(handler.server.responseListeners, route.controller.responseListeners...)
+	apiListeners := append(handler.server.apiListeners, route.controller.apiListeners...)
+	interrupts := append(handler.server.interrupts, route.controller.interrupts...)
+	handlerFunc := translate(
+		api,
+		requestListeners,
+		responseListeners,
+		apiListeners,
+		handler.server.errorAction,
+		pathParams,
+		queries,
+		interrupts,
+		resolveCodecs(route.controller.codecs, handler.server.codecs),
+	)
+	serveCompressed(resolveCompression(route.controller.compression, handler.server.compression), handlerFunc, writer, request)
+}
+
+// otherMethodsOf returns the (sorted) methods which the path of the request is answered with, other than the request's own,
+// alongside the first route matching the path and its path parameters.
+func (handler apiHandler) otherMethodsOf(request *http.Request) (Route, Params, []string) {
+	var first Route
+	var firstPathParams Params
+	var allowed []string
+	seen := map[string]bool{request.Method: true}
+	for _, controller := range handler.server.Controllers {
+		for _, route := range controller.routes {
+			matches, pathParams := route.matchesPath(request.URL.Path)
+			if !matches {
+				continue
+			}
+			for _, method := range route.methods() {
+				if seen[method] {
+					continue
+				}
+				if allowed == nil {
+					first, firstPathParams = route, pathParams
+				}
+				seen[method] = true
+				allowed = append(allowed, method)
+			}
+		}
+	}
+	sort.Strings(allowed)
+	return first, firstPathParams, allowed
+}
+
 func (handler apiHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	var done bool
+	// the first route which matched the path, but rejected the queries
+	var queryErr error
+	var queryErrRoute Route
+	var queryErrPathParams Params
 	for _, route := range handler.methodWithRoutes[request.Method] {
 		accepts, pathParams := route.acceptsAndPathParams(request)
-		if accepts && acceptsAllQueries(route.expectedQueries, request.URL.Query()) {
-			requestListeners := append(handler.server.requestListeners, route.controller.requestListeners...)
-			responseListeners := append(handler.server.responseListeners, route.controller.responseListeners...)
-			apiListeners := append(handler.server.apiListeners, route.controller.apiListeners...)
-			interrupts := append(handler.server.interrupts, route.controller.interrupts...)
-			handlerFunc := translate(
-				route.Action,
-				requestListeners,
-				responseListeners,
-				apiListeners,
-				handler.server.errorAction,
-				pathParams,
-				interrupts,
-			)
-			handlerFunc(writer, request)
+		if !accepts {
+			continue
+		}
+		queries, err := resolveQueries(route.expectedQueries, request.URL.Query())
+		if err != nil {
+			if queryErr == nil {
+				queryErr, queryErrRoute, queryErrPathParams = err, route, pathParams
+			}
+			continue
+		}
+		handler.serve(route, route.Action, pathParams, queries, writer, request)
+		done = true
+		break
+	}
+	if !done && queryErr != nil {
+		handler.serve(queryErrRoute, invalidQueryAction(queryErr), queryErrPathParams, request.URL.Query(), writer, request)
+		done = true
+	}
+	// the path exists, but not for the method of the request
+	if !done {
+		if route, pathParams, allowed := handler.otherMethodsOf(request); len(allowed) > 0 {
+			handler.serve(route, methodNotAllowedAction(allowed), pathParams, request.URL.Query(), writer, request)
 			done = true
-			break
 		}
 	}
 	// no route matches the request
 	if !done {
-		rc := requestContextFromHttpRequest(request, writer, nil)
+		codecs := resolveCodecs(handler.server.codecs)
+		rc := requestContextFromHttpRequest(withCodecs(request, codecs), writer, nil)
 		status := handler.server.notFoundAction(rc)
 		statusCode := status.StatusCode
-		bodyBytes, contentType, marshalErr := marshall(status.Entity)
+		bodyBytes, contentType, marshalErr := marshall(status.Entity, codecs)
 		if marshalErr != nil {
 			_ = stginLogger.ErrorF(
 				"could not marshal not found action result:\n\t%v%v%v",
 				colored.RED, fmt.Sprint(marshalErr), colored.ResetPrevColor,
 			)
-			bodyBytes, _ = json.Marshal(&generalFailureMessage{
-				StatusCode: http.StatusNotFound,
-				Path:       request.URL.Path,
-				Message:    "route not found",
-				Method:     request.Method,
-			})
+			bodyBytes, _ = NewProblem(http.StatusNotFound, "route not found").MarshalJSON()
 			statusCode = http.StatusNotFound
-			contentType = applicationJson
+			contentType = applicationProblemJson
 		}
 		writer.Header().Set(contentTypeKey, contentType)
 		writer.WriteHeader(statusCode)
@@ -290,13 +414,13 @@ func (server *Server) HttpHandler() http.Handler {
 	for _, controller := range server.Controllers {
 		for _, route := range controller.routes {
 			var log string
+			methodWithRoutes[route.Method] = append(methodWithRoutes[route.Method], route)
 			if !route.isStaticDir() {
-				methodWithRoutes[route.Method] = append(methodWithRoutes[route.Method], route)
 				log = routeAppendLog(controller.Name, route.Method, route.Path)
 			} else {
-				dir := route.dir
-				log = bindStaticDirLog(route.Path, dir)
-				mux.Handle(route.Path, http.StripPrefix(route.Path, http.FileServer(http.Dir(dir))))
+				// static directories also answer HEAD requests
+				methodWithRoutes[http.MethodHead] = append(methodWithRoutes[http.MethodHead], route)
+				log = bindStaticDirLog(route.staticPrefix(), route.static.description)
 			}
 			_ = stginLogger.Info(log)
 		}
@@ -307,10 +431,41 @@ func (server *Server) HttpHandler() http.Handler {
 
 // Start executes the server over the specified address.
 // In case any uncaught error or panic happens, and is not recovered in the server's error handler,
-// the error value is returned as a result.
+// the error value is returned as a result. Once the server is shut down using Shutdown, nil is returned.
 func (server *Server) Start() error {
+	httpServer := &http.Server{Addr: server.addr, Handler: server.HttpHandler()}
+	server.lifecycle.Lock()
+	server.httpServer = httpServer
+	server.lifecycle.Unlock()
 	_ = stginLogger.InfoF("started server over address: %s%s%s", colored.YELLOW, server.addr, colored.ResetPrevColor)
-	return http.ListenAndServe(server.addr, server.HttpHandler())
+	if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Shutdown gracefully shuts down the server; the hubs of the server are closed first, so that long-lived streams
+// subscribed to them can finish, and then the server waits for the active requests until the context is done.
+func (server *Server) Shutdown(ctx context.Context) error {
+	server.lifecycle.Lock()
+	hubs, httpServer := server.hubs, server.httpServer
+	server.lifecycle.Unlock()
+	for _, hub := range hubs {
+		hub.Close()
+	}
+	if httpServer == nil {
+		return nil
+	}
+	return httpServer.Shutdown(ctx)
+}
+
+// NewHub creates a publish/subscribe hub, which is closed when the server shuts down.
+func (server *Server) NewHub(config HubConfig) *Hub {
+	hub := NewHub(config)
+	server.lifecycle.Lock()
+	server.hubs = append(server.hubs, hub)
+	server.lifecycle.Unlock()
+	return hub
 }
 
 // NewServer returns a pointer to a basic stgin Server.
@@ -336,7 +491,7 @@ func DefaultServer(addr string) *Server {
 func (server *Server) executeInternal(request *http.Request) Status {
 	var headers http.Header
 	if request.Header == nil {
-		headers = emptyHeaders
+		headers = http.Header{}
 	} else {
 		headers = request.Header
 	}
@@ -388,25 +543,32 @@ func (server *Server) executeInternal(request *http.Request) Status {
 	var done bool
 	go func() {
 		var result Status
+		var queryErr error
 		for _, c := range server.Controllers {
 			if done { break }
 			for _, route := range c.routes {
 				matches, pathParams := route.acceptsAndPathParams(request)
-				if matches && acceptsAllQueries(route.expectedQueries, request.URL.Query()) {
-					rc.PathParams = PathParams{pathParams}
-					done = true
-					result = route.Action(rc)
-					break
+				if !matches {
+					continue
+				}
+				queries, err := resolveQueries(route.expectedQueries, request.URL.Query())
+				if err != nil {
+					if queryErr == nil {
+						queryErr = err
+					}
+					continue
 				}
+				rc.PathParams = PathParams{pathParams}
+				rc.QueryParams = rc.QueryParams.withResolved(queries)
+				done = true
+				result = route.Action(rc)
+				break
 			}
 		}
-		if !done {
-			result = NotFound(Json(&generalFailureMessage{
-				StatusCode: 404,
-				Path:       request.URL.Path,
-				Message:    "not found",
-				Method:     request.Method,
-			}))
+		if !done && queryErr != nil {
+			result = invalidQueryAction(queryErr)(rc)
+		} else if !done {
+			result = failureResponse(rc, http.StatusNotFound, "not found")
 		}
 
 		for _, modifier := range responseListeners {
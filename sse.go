@@ -0,0 +1,226 @@
+package stgin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const lastEventIDKey = "Last-Event-ID"
+
+// defaultHeartbeatInterval is short enough to keep the connection open through most proxies and load balancers.
+const defaultHeartbeatInterval = 15 * time.Second
+
+// SSEConfig holds the specifications of server-sent event streams.
+type SSEConfig struct {
+	// HeartbeatInterval is the interval of the comments sent to keep the connection alive while no events are sent.
+	// Zero disables heartbeats.
+	HeartbeatInterval time.Duration
+	// Retry is sent to the client as the reconnection time, if it's not zero.
+	Retry time.Duration
+}
+
+// EventStream is an open server-sent events stream to a client.
+// Events are flushed to the client as soon as they're sent, and all the methods are safe to be called concurrently.
+// Once the client disconnects, sending fails with the request context's error.
+type EventStream interface {
+	// Send sends an event to the client. event and id can be empty; the client uses "message" as the event,
+	// and keeps the last id. Strings and byte slices are sent as data directly, and other values are encoded as JSON.
+	Send(event string, data any, id string) error
+	// Comment sends a comment to the client, which is ignored by browsers.
+	Comment(comment string) error
+	// Retry tells the client how long to wait before reconnecting if the connection is lost.
+	Retry(retry time.Duration) error
+	// LastEventID is the id of the last event the client received before reconnecting (the Last-Event-ID header),
+	// so that the stream can be resumed from there.
+	LastEventID() string
+	// Request is the request which opened the stream.
+	Request() RequestContext
+	// Done is closed when the client disconnects.
+	Done() <-chan struct{}
+}
+
+// SSE creates an API which opens a server-sent events (text/event-stream) stream, and hands it to the given function.
+// The stream is open until the function returns, and heartbeat comments are sent every 15 seconds.
+// Streams are written after the API is done, so timeouts and other interrupts do not close them.
+func SSE(handler func(stream EventStream)) API {
+	return SSEWithConfig(handler, SSEConfig{HeartbeatInterval: defaultHeartbeatInterval})
+}
+
+// SSEWithConfig is just like SSE, using the given config.
+func SSEWithConfig(handler func(stream EventStream), config SSEConfig) API {
+	if handler == nil {
+		printStacktrace("")
+		panic("cannot use nil as an event stream handler")
+	}
+	return func(request RequestContext) Status {
+		return Ok(sseEntity{request: request, handler: handler, config: config})
+	}
+}
+
+type sseEntity struct {
+	request RequestContext
+	handler func(stream EventStream)
+	config  SSEConfig
+}
+
+func (se sseEntity) ContentType() string {
+	return "text/event-stream"
+}
+
+func (se sseEntity) Bytes() ([]byte, error) {
+	return nil, errors.New("event streams can only be written into responses")
+}
+
+func (se sseEntity) serve(writer http.ResponseWriter, request *http.Request, statusCode int) error {
+	writer.Header().Set(contentTypeKey, se.ContentType())
+	writer.Header().Set("Cache-Control", "no-cache")
+	// stops proxies like nginx from buffering the events
+	writer.Header().Set("X-Accel-Buffering", "no")
+	writer.Header().Del("Content-Length")
+	writer.WriteHeader(statusCode)
+	stream := &eventStream{writer: writer, ctx: request.Context(), request: se.request}
+	if err := stream.Retry(se.config.Retry); err != nil {
+		return nil
+	}
+	if err := stream.write(""); err != nil {
+		return nil
+	}
+	finished := make(chan struct{})
+	defer stream.close(finished)
+	if se.config.HeartbeatInterval > 0 {
+		go stream.heartbeat(se.config.HeartbeatInterval, finished)
+	}
+	se.handler(stream)
+	return nil
+}
+
+var errEventStreamClosed = errors.New("event stream is closed")
+
+type eventStream struct {
+	mutex   sync.Mutex
+	writer  http.ResponseWriter
+	ctx     context.Context
+	request RequestContext
+	closed  bool
+}
+
+// write writes the given fields and flushes them, so that they're received by the client immediately.
+func (es *eventStream) write(fields string) error {
+	es.mutex.Lock()
+	defer es.mutex.Unlock()
+	if es.closed {
+		return errEventStreamClosed
+	}
+	if err := es.ctx.Err(); err != nil {
+		return err
+	}
+	if fields != "" {
+		if _, err := es.writer.Write([]byte(fields)); err != nil {
+			return err
+		}
+	}
+	if flusher, isFlusher := es.writer.(http.Flusher); isFlusher {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// close stops the stream once the handler returns, since the response writer cannot be used afterwards.
+func (es *eventStream) close(finished chan struct{}) {
+	es.mutex.Lock()
+	es.closed = true
+	es.mutex.Unlock()
+	close(finished)
+}
+
+func (es *eventStream) heartbeat(interval time.Duration, finished chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if es.Comment("heartbeat") != nil {
+				return
+			}
+		case <-finished:
+			return
+		case <-es.ctx.Done():
+			return
+		}
+	}
+}
+
+// eventLines splits the value into lines, which is how multi-line values are sent.
+func eventLines(value string) []string {
+	value = strings.ReplaceAll(value, "\r\n", "\n")
+	value = strings.ReplaceAll(value, "\r", "\n")
+	return strings.Split(value, "\n")
+}
+
+func (es *eventStream) Send(event string, data any, id string) error {
+	if strings.ContainsAny(event, "\r\n") {
+		return fmt.Errorf("event name '%s' contains line breaks", event)
+	}
+	if strings.ContainsAny(id, "\r\n\x00") {
+		return fmt.Errorf("event id '%s' contains line breaks or null characters", id)
+	}
+	var payload string
+	switch value := data.(type) {
+	case string:
+		payload = value
+	case []byte:
+		payload = string(value)
+	default:
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		payload = string(encoded)
+	}
+	var fields strings.Builder
+	if id != "" {
+		fields.WriteString("id: " + id + "\n")
+	}
+	if event != "" {
+		fields.WriteString("event: " + event + "\n")
+	}
+	for _, line := range eventLines(payload) {
+		fields.WriteString("data: " + line + "\n")
+	}
+	fields.WriteString("\n")
+	return es.write(fields.String())
+}
+
+func (es *eventStream) Comment(comment string) error {
+	var fields strings.Builder
+	for _, line := range eventLines(comment) {
+		fields.WriteString(": " + line + "\n")
+	}
+	fields.WriteString("\n")
+	return es.write(fields.String())
+}
+
+func (es *eventStream) Retry(retry time.Duration) error {
+	if retry <= 0 {
+		return nil
+	}
+	return es.write(fmt.Sprintf("retry: %d\n\n", retry.Milliseconds()))
+}
+
+func (es *eventStream) LastEventID() string {
+	return es.request.Headers.Get(lastEventIDKey)
+}
+
+func (es *eventStream) Request() RequestContext {
+	return es.request
+}
+
+func (es *eventStream) Done() <-chan struct{} {
+	return es.ctx.Done()
+}
@@ -0,0 +1,93 @@
+package stgin
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readEvent reads the lines of the next event (or comment) from an event stream.
+func readEvent(reader *bufio.Reader) []string {
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return lines
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestSSE(t *testing.T) {
+	controller := NewController("Events", "")
+	controller.AddRoutes(GET("/events", SSEWithConfig(func(stream EventStream) {
+		_ = stream.Send("", "resumed after "+stream.LastEventID(), "")
+		_ = stream.Send("update", map[string]int{"visitors": 12}, "13")
+		_ = stream.Send("log", "line 1\nline 2", "")
+		time.Sleep(120 * time.Millisecond)
+	}, SSEConfig{Retry: 3 * time.Second, HeartbeatInterval: 50 * time.Millisecond})))
+	controller.SetTimeout(30 * time.Millisecond)
+	server := streamServer(controller)
+	defer server.Close()
+
+	request, _ := http.NewRequest("GET", server.URL+"/events", nil)
+	request.Header.Set("Last-Event-ID", "12")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK || response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("event stream was not opened, got: %d %v", response.StatusCode, response.Header)
+	}
+	reader := bufio.NewReader(response.Body)
+	expected := [][]string{
+		{"retry: 3000"},
+		{"data: resumed after 12"},
+		{"id: 13", "event: update", `data: {"visitors":12}`},
+		{"event: log", "data: line 1", "data: line 2"},
+		{": heartbeat"},
+	}
+	for _, expectedEvent := range expected {
+		event := readEvent(reader)
+		if strings.Join(event, "\n") != strings.Join(expectedEvent, "\n") {
+			t.Fatalf("expected event %v, got %v", expectedEvent, event)
+		}
+	}
+}
+
+func TestSSEClientDisconnect(t *testing.T) {
+	disconnected := make(chan error, 1)
+	controller := NewController("Events", "")
+	controller.AddRoutes(GET("/events", SSE(func(stream EventStream) {
+		_ = stream.Send("ready", "", "")
+		<-stream.Done()
+		disconnected <- stream.Send("late", "", "")
+	})))
+	server := streamServer(controller)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	request, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/events", nil)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readEvent(bufio.NewReader(response.Body))
+	cancel()
+	select {
+	case err = <-disconnected:
+		if err == nil {
+			t.Fatal("sending events after the client disconnected did not fail")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("client disconnect was not detected")
+	}
+}
@@ -0,0 +1,357 @@
+package stgin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// StaticConfig holds the specifications of how a static directory should be served.
+// The zero value serves files with "index.html" as the index file, without directory listing, SPA fallback or cache policies.
+type StaticConfig struct {
+	// IndexFiles are the file names which are looked up (in order) when a directory is requested.
+	// Defaults to "index.html".
+	IndexFiles []string
+	// ListDirectories enables listing the contents of directories which have no index file.
+	ListDirectories bool
+	// SPAFallback is the path of a file inside the directory (i.e., "index.html"), which is served instead of 404
+	// for the requests that explicitly accept text/html, so that single page applications can use the history API.
+	// Missing paths with file extensions (like "/app.js") are still responded with 404.
+	SPAFallback string
+	// CacheControl maps file extensions (i.e., ".js") to the Cache-Control header of the files with that extension.
+	// Use something like "public, max-age=31536000, immutable" for hashed assets.
+	CacheControl map[string]string
+	// DefaultCacheControl is the Cache-Control header of the files whose extension is not in CacheControl.
+	DefaultCacheControl string
+	// DisableETag stops generating ETag headers (based on file size and modification time) for the served files.
+	DisableETag bool
+	// AllowDotFiles allows serving files and directories whose names start with a dot, they're not found by default.
+	AllowDotFiles bool
+	// DisablePrecompressed stops looking for precompressed siblings of the requested files (i.e., "app.js.br" or
+	// "app.js.gz"), which are otherwise served to the clients accepting their encoding.
+	DisablePrecompressed bool
+}
+
+func (config StaticConfig) indexFiles() []string {
+	if len(config.IndexFiles) == 0 {
+		return []string{"index.html"}
+	}
+	return config.IndexFiles
+}
+
+func (config StaticConfig) cacheControlFor(name string) string {
+	if policy, found := config.CacheControl[strings.ToLower(path.Ext(name))]; found {
+		return policy
+	}
+	return config.DefaultCacheControl
+}
+
+// staticDir is an http.Handler serving the files inside fsys, the request paths must already be stripped from the route prefix.
+// If it's created for a directory on disk, root holds the directory path, so that symlinks escaping it can be detected.
+type staticDir struct {
+	fsys        fs.FS
+	root        string
+	description string
+	config      StaticConfig
+	hashedETags sync.Map // fs path -> hashedETag, of the files without modification times
+}
+
+type hashedETag struct {
+	size int64
+	etag string
+}
+
+func staticDirOnDisk(dir string, config StaticConfig) *staticDir {
+	return &staticDir{fsys: os.DirFS(dir), root: dir, description: dir, config: config}
+}
+
+func staticDirOnFS(fsys fs.FS, config StaticConfig) *staticDir {
+	return &staticDir{fsys: fsys, description: fmt.Sprintf("%T", fsys), config: config}
+}
+
+func hasDotSegment(name string) bool {
+	for _, segment := range strings.Split(name, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsHtml reports whether the request explicitly accepts html, like browser navigations do.
+// Wildcards are not enough, since scripts, styles, images and fetch calls accept */* as well.
+func acceptsHtml(headers http.Header) bool {
+	for _, mediaRange := range parseQualityList(headers.Get("Accept")) {
+		if mediaRange.value == "text/html" {
+			return mediaRange.quality > 0
+		}
+	}
+	return false
+}
+
+// fsName converts a cleaned, slash-rooted request path into an fs.FS name.
+func fsName(name string) string {
+	if name == "/" {
+		return "."
+	}
+	return strings.TrimPrefix(name, "/")
+}
+
+// resolve returns the name of the given path inside fsys, and its file info.
+// Names containing dot segments (if not allowed) and symlinks pointing outside the root directory are not found.
+func (sd *staticDir) resolve(name string) (string, fs.FileInfo, error) {
+	if !sd.config.AllowDotFiles && hasDotSegment(name) {
+		return "", nil, fs.ErrNotExist
+	}
+	if sd.root != "" {
+		root, err := filepath.EvalSymlinks(sd.root)
+		if err != nil {
+			return "", nil, err
+		}
+		resolved, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			return "", nil, err
+		}
+		if resolved != root && !strings.HasPrefix(resolved, root+string(filepath.Separator)) {
+			return "", nil, fs.ErrNotExist
+		}
+	}
+	info, err := fs.Stat(sd.fsys, fsName(name))
+	if err != nil {
+		return "", nil, err
+	}
+	return fsName(name), info, nil
+}
+
+// api returns the API which serves the static directory, mounted on the given route prefix (like "/static/").
+func (sd *staticDir) api(prefix string) API {
+	return func(request RequestContext) Status {
+		if request.Url+"/" == prefix {
+			return Redirect(http.StatusMovedPermanently, prefix).KeepQuery()
+		}
+		relative := "/" + strings.TrimPrefix(request.Url, prefix)
+		return sd.serve(request, relative)
+	}
+}
+
+func (sd *staticDir) serve(request RequestContext, relative string) Status {
+	name := path.Clean(relative)
+	fsPath, info, err := sd.resolve(name)
+	if err != nil {
+		return sd.fallback(request, err)
+	}
+	if info.IsDir() {
+		if !strings.HasSuffix(relative, "/") {
+			return Redirect(http.StatusMovedPermanently, path.Base(request.Url)+"/").KeepQuery()
+		}
+		for _, index := range sd.config.indexFiles() {
+			if indexPath, indexInfo, indexErr := sd.resolve(path.Join(name, index)); indexErr == nil && !indexInfo.IsDir() {
+				return sd.file(request, indexPath, indexInfo)
+			}
+		}
+		if sd.config.ListDirectories {
+			return sd.listDirectory(request, fsPath)
+		}
+		return sd.fallback(request, fs.ErrNotExist)
+	}
+	return sd.file(request, fsPath, info)
+}
+
+func (sd *staticDir) fallback(request RequestContext, err error) Status {
+	isMissing := errors.Is(err, fs.ErrNotExist)
+	if isMissing && sd.config.SPAFallback != "" && path.Ext(request.Url) == "" && acceptsHtml(request.Headers) {
+		if fallbackPath, info, fallbackErr := sd.resolve(path.Clean("/" + sd.config.SPAFallback)); fallbackErr == nil && !info.IsDir() {
+			return sd.file(request, fallbackPath, info)
+		}
+	}
+	if isMissing {
+		return notFoundDefaultAction(request)
+	} else if errors.Is(err, fs.ErrPermission) {
+		return failureResponse(request, http.StatusForbidden, "forbidden")
+	}
+	_ = stginLogger.ErrorF("error serving static path '%s': %s", request.Url, err.Error())
+	return failureResponse(request, http.StatusInternalServerError, "internal server error")
+}
+
+// precompressedEncodings are the content encodings of precompressed files, in the order they're preferred by the server.
+var precompressedEncodings = []struct {
+	encoding  string
+	extension string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// precompressedSibling finds the best precompressed sibling of the given file, which the client accepts.
+// It also reports whether the file has any precompressed siblings, so that the response can vary on Accept-Encoding.
+func (sd *staticDir) precompressedSibling(headers http.Header, fsPath string) (encoding string, siblingPath string, sibling fs.FileInfo, hasSiblings bool) {
+	accepted := parseQualityList(headers.Get("Accept-Encoding"))
+	bestQuality := 0.0
+	for _, candidate := range precompressedEncodings {
+		candidatePath, info, err := sd.resolve("/" + fsPath + candidate.extension)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		hasSiblings = true
+		quality := -1.0
+		for _, acceptedEncoding := range accepted {
+			if acceptedEncoding.value == candidate.encoding || (acceptedEncoding.value == "*" && quality < 0) {
+				quality = acceptedEncoding.quality
+			}
+		}
+		if quality > bestQuality {
+			bestQuality = quality
+			encoding, siblingPath, sibling = candidate.encoding, candidatePath, info
+		}
+	}
+	return
+}
+
+// file returns the status serving the given file, or its best precompressed sibling.
+func (sd *staticDir) file(request RequestContext, fsPath string, info fs.FileInfo) Status {
+	headers := http.Header{}
+	entity := staticFile{fsys: sd.fsys, fsPath: fsPath, name: info.Name(), info: info}
+	if !sd.config.DisablePrecompressed {
+		encoding, siblingPath, sibling, hasSiblings := sd.precompressedSibling(request.Headers, fsPath)
+		if hasSiblings {
+			headers.Add("Vary", "Accept-Encoding")
+		}
+		if encoding != "" {
+			entity.fsPath, entity.info, entity.encoding = siblingPath, sibling, encoding
+		}
+	}
+	if cacheControl := sd.config.cacheControlFor(info.Name()); cacheControl != "" {
+		headers.Set("Cache-Control", cacheControl)
+	}
+	if !sd.config.DisableETag {
+		if etag := sd.etag(entity.fsPath, entity.info); etag != "" {
+			headers.Set("ETag", etag)
+		}
+	}
+	return Status{StatusCode: http.StatusOK, Entity: entity, Headers: headers}
+}
+
+// etag returns the weak ETag of the file. Files without modification times (like embedded files) are hashed once
+// per path, since they're not expected to change, unless their size does.
+func (sd *staticDir) etag(fsPath string, info fs.FileInfo) string {
+	open := func() (fs.File, error) { return sd.fsys.Open(fsPath) }
+	if !info.ModTime().IsZero() {
+		return weakETag(info, open)
+	}
+	if cached, found := sd.hashedETags.Load(fsPath); found && cached.(hashedETag).size == info.Size() {
+		return cached.(hashedETag).etag
+	}
+	etag := weakETag(info, open)
+	if etag != "" {
+		sd.hashedETags.Store(fsPath, hashedETag{size: info.Size(), etag: etag})
+	}
+	return etag
+}
+
+// weakETag generates a weak ETag based on the file's size and modification time.
+// Files without modification time (like embedded files) are hashed instead.
+func weakETag(info fs.FileInfo, open func() (fs.File, error)) string {
+	if !info.ModTime().IsZero() {
+		return fmt.Sprintf(`W/"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+	}
+	file, err := open()
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	hash := fnv.New64a()
+	if _, err = io.Copy(hash, file); err != nil {
+		return ""
+	}
+	return fmt.Sprintf(`W/"%x"`, hash.Sum64())
+}
+
+// readSeeker returns the file itself if it supports seeking (like os and embed files), or reads it into memory.
+func readSeeker(file fs.File) (io.ReadSeeker, error) {
+	if seeker, isSeeker := file.(io.ReadSeeker); isSeeker {
+		return seeker, nil
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(content), nil
+}
+
+func (sd *staticDir) listDirectory(request RequestContext, fsPath string) Status {
+	entries, err := fs.ReadDir(sd.fsys, fsPath)
+	if err != nil {
+		return sd.fallback(request, err)
+	}
+	var listing bytes.Buffer
+	listing.WriteString("<pre>\n")
+	for _, entry := range entries {
+		name := entry.Name()
+		if !sd.config.AllowDotFiles && strings.HasPrefix(name, ".") {
+			continue
+		}
+		if entry.IsDir() {
+			name += "/"
+		}
+		link := url.URL{Path: name}
+		_, _ = fmt.Fprintf(&listing, "<a href=\"%s\">%s</a>\n", link.String(), html.EscapeString(name))
+	}
+	listing.WriteString("</pre>\n")
+	return Ok(bytesEntity{contentType: "text/html; charset=utf-8", bytes: listing.Bytes()})
+}
+
+// staticFile is the response entity of the files served from static directories.
+// It is written using http.ServeContent, which handles conditional and range requests.
+type staticFile struct {
+	fsys     fs.FS
+	fsPath   string
+	name     string // the name of the original file, in case a precompressed sibling is served
+	info     fs.FileInfo
+	encoding string
+}
+
+func (sf staticFile) ContentType() string {
+	if sf.encoding != "" {
+		// precompressed content cannot be sniffed
+		return detectContentType(sf.name, nil)
+	}
+	return detectContentType(sf.name, func() (fs.File, error) { return sf.fsys.Open(sf.fsPath) })
+}
+
+func (sf staticFile) Bytes() ([]byte, error) {
+	return fs.ReadFile(sf.fsys, sf.fsPath)
+}
+
+func (sf staticFile) serve(writer http.ResponseWriter, request *http.Request, statusCode int) error {
+	file, err := sf.fsys.Open(sf.fsPath)
+	if err != nil {
+		_ = stginLogger.ErrorF("error serving static file '%s': %s", sf.fsPath, err.Error())
+		writeProblem(writer, request, http.StatusInternalServerError, "internal server error")
+		return nil
+	}
+	defer file.Close()
+	content, err := readSeeker(file)
+	if err != nil {
+		_ = stginLogger.ErrorF("error serving static file '%s': %s", sf.fsPath, err.Error())
+		writeProblem(writer, request, http.StatusInternalServerError, "internal server error")
+		return nil
+	}
+	if sf.encoding != "" {
+		// the content type cannot be sniffed from the encoded content
+		writer.Header().Set(contentTypeKey, sf.ContentType())
+		writer.Header().Set("Content-Encoding", sf.encoding)
+	}
+	serveContent(writer, request, statusCode, sf.name, sf.info.ModTime(), content)
+	return nil
+}
@@ -0,0 +1,325 @@
+package stgin
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func mkStaticDir(t *testing.T) string {
+	root := t.TempDir()
+	files := map[string]string{
+		"index.html":         "<h1>home</h1>",
+		"app.js":             "console.log('hi')",
+		".env":               "SECRET=1",
+		"docs/guide.txt":     "guide",
+		"assets/index.html":  "<h1>assets</h1>",
+		"empty/.placeholder": "",
+	}
+	for name, content := range files {
+		fullPath := filepath.Join(root, filepath.FromSlash(name))
+		_ = os.MkdirAll(filepath.Dir(fullPath), 0o755)
+		if err := os.WriteFile(fullPath, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func staticHandler(routes ...Route) http.Handler {
+	controller := NewController("Static", "")
+	controller.AddRoutes(routes...)
+	server := &Server{Controllers: []*Controller{controller}, notFoundAction: notFoundDefaultAction}
+	return server.HttpHandler()
+}
+
+func serveStatic(handler http.Handler, target string, accept string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", target, nil)
+	if accept != "" {
+		request.Header.Set("Accept", accept)
+	}
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestStaticDir(t *testing.T) {
+	root := mkStaticDir(t)
+	outside := t.TempDir()
+	_ = os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o644)
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Skip("symlinks are not supported")
+	}
+	handler := staticHandler(StaticDirWithConfig("/static", root, StaticConfig{
+		CacheControl: map[string]string{".js": "public, max-age=31536000, immutable"},
+	}))
+
+	response := serveStatic(handler, "/static/app.js", "")
+	if response.Code != http.StatusOK || response.Header().Get("Cache-Control") != "public, max-age=31536000, immutable" {
+		t.Fatalf("static file was not served with its cache policy: %d %v", response.Code, response.Header())
+	}
+	etag := response.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("static file was served without an ETag")
+	}
+	conditional := httptest.NewRequest("GET", "/static/app.js", nil)
+	conditional.Header.Set("If-None-Match", etag)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, conditional)
+	if recorder.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for matching ETag, got %d", recorder.Code)
+	}
+
+	if response = serveStatic(handler, "/static/assets/", ""); response.Body.String() != "<h1>assets</h1>" {
+		t.Fatalf("index file was not served for directory, got: %s", response.Body.String())
+	}
+	for _, target := range []string{"/static/.env", "/static/docs/", "/static/escape/secret.txt", "/static/missing"} {
+		if response = serveStatic(handler, target, ""); response.Code != http.StatusNotFound {
+			t.Errorf("expected 404 for %s, got %d", target, response.Code)
+		}
+	}
+}
+
+func TestStaticDirRedirects(t *testing.T) {
+	root := mkStaticDir(t)
+	handler := staticHandler(StaticDir("/st", root))
+	for target, location := range map[string]string{
+		"/st":          "/st/",
+		"/st?v=2":      "/st/?v=2",
+		"/st/docs":     "/st/docs/",
+		"/st/docs?x=1": "/st/docs/?x=1",
+	} {
+		response := serveStatic(handler, target, "")
+		if response.Code != http.StatusMovedPermanently || response.Header().Get("Location") != location {
+			t.Errorf("expected %s to redirect to %s, got %d %q", target, location, response.Code, response.Header().Get("Location"))
+		}
+	}
+	if response := serveStatic(handler, "/stx", ""); response.Code != http.StatusNotFound {
+		t.Fatalf("paths sharing the prefix should not match the directory, got %d", response.Code)
+	}
+}
+
+// failingFS fails to open anything but the root directory, like a broken disk or network file system.
+type failingFS struct{ fstest.MapFS }
+
+func (ffs failingFS) Open(name string) (fs.File, error) {
+	if name == "." {
+		return ffs.MapFS.Open(name)
+	}
+	return nil, errors.New("input/output error")
+}
+
+func (ffs failingFS) Stat(name string) (fs.FileInfo, error) {
+	if name == "." {
+		return ffs.MapFS.Stat(name)
+	}
+	return nil, errors.New("input/output error")
+}
+
+func (ffs failingFS) ReadDir(string) ([]fs.DirEntry, error) {
+	return nil, errors.New("input/output error")
+}
+
+// unreadableFS can stat the files, but fails to open them.
+type unreadableFS struct{ fstest.MapFS }
+
+func (ufs unreadableFS) Open(name string) (fs.File, error) {
+	if name == "." {
+		return ufs.MapFS.Open(name)
+	}
+	return nil, errors.New("input/output error")
+}
+
+func TestStaticFSFailures(t *testing.T) {
+	files := fstest.MapFS{"a.txt": {Data: []byte("a")}}
+	handler := staticHandler(
+		StaticFSWithConfig("/broken", failingFS{files}, StaticConfig{ListDirectories: true}),
+		StaticFS("/unreadable", unreadableFS{files}),
+	)
+	for _, target := range []string{"/broken/a.txt", "/broken/", "/unreadable/a.txt"} {
+		response := serveStatic(handler, target, "")
+		if response.Code != http.StatusInternalServerError || response.Header().Get("Content-Type") != applicationProblemJson {
+			t.Errorf("expected a 500 problem for %s, got %d %v", target, response.Code, response.Header())
+		}
+	}
+}
+
+func TestStaticDirListingAndFallback(t *testing.T) {
+	root := mkStaticDir(t)
+	handler := staticHandler(StaticDirWithConfig("/app", root, StaticConfig{ListDirectories: true, SPAFallback: "index.html"}))
+
+	response := serveStatic(handler, "/app/docs/", "")
+	if response.Code != http.StatusOK || response.Body.String() != "<pre>\n<a href=\"guide.txt\">guide.txt</a>\n</pre>\n" {
+		t.Fatalf("directory was not listed as expected, got: %s", response.Body.String())
+	}
+	if response = serveStatic(handler, "/app/users/12", "text/html"); response.Body.String() != "<h1>home</h1>" {
+		t.Fatalf("spa fallback was not served, got: %d %s", response.Code, response.Body.String())
+	}
+	for target, accept := range map[string]string{
+		"/app/missing.json": "application/json",
+		"/app/users/13":     "*/*",
+		"/app/users/14":     "",
+		"/app/users/15":     "text/html;q=0, */*",
+		"/app/main.js":      "text/html",
+	} {
+		if response = serveStatic(handler, target, accept); response.Code != http.StatusNotFound {
+			t.Errorf("spa fallback was served for %s (Accept: %q), got: %d", target, accept, response.Code)
+		}
+	}
+	if response = serveStatic(handler, "/app/users/12", "text/html,application/xhtml+xml,*/*;q=0.8"); response.Code != http.StatusOK {
+		t.Fatalf("spa fallback was not served for browser navigations, got: %d", response.Code)
+	}
+}
+
+var assetsFS = fstest.MapFS{
+	"public/index.html":  {Data: []byte("<h1>embedded</h1>")},
+	"public/css/app.css": {Data: []byte("body {}")},
+	"templates/mail.txt": {Data: []byte("hello")},
+}
+
+func TestStaticFS(t *testing.T) {
+	public, _ := fs.Sub(assetsFS, "public")
+	handler := staticHandler(StaticFS("/embedded", public))
+
+	if response := serveStatic(handler, "/embedded/", ""); response.Body.String() != "<h1>embedded</h1>" {
+		t.Fatalf("index file was not served from fs, got: %s", response.Body.String())
+	}
+	response := serveStatic(handler, "/embedded/css/app.css", "")
+	if response.Code != http.StatusOK || response.Body.String() != "body {}" {
+		t.Fatalf("file was not served from fs, got: %d %s", response.Code, response.Body.String())
+	}
+	if response = serveStatic(handler, "/embedded/templates/mail.txt", ""); response.Code != http.StatusNotFound {
+		t.Fatalf("file outside the sub fs got served, got: %d", response.Code)
+	}
+}
+
+func TestFileFrom(t *testing.T) {
+	status := FileFrom(assetsFS, "templates/mail.txt")
+	bytes, _ := status.Entity.Bytes()
+	if status.StatusCode != http.StatusOK || string(bytes) != "hello" {
+		t.Fatalf("file was not read from fs, got: %d %s", status.StatusCode, string(bytes))
+	}
+	status = FileFrom(assetsFS, "templates/missing.txt")
+	if status.StatusCode != http.StatusNotFound || status.Entity.ContentType() != applicationProblemJson {
+		t.Fatalf("expected a 404 problem for missing file, got %d %s", status.StatusCode, status.Entity.ContentType())
+	}
+}
+
+func TestStaticPrecompressed(t *testing.T) {
+	assets := fstest.MapFS{
+		"app.js":    {Data: []byte("console.log('plain')")},
+		"app.js.br": {Data: []byte("brotli bytes")},
+		"app.js.gz": {Data: []byte("gzip bytes")},
+		"style.css": {Data: []byte("body {}")},
+	}
+	handler := staticHandler(StaticFS("/compressed", assets))
+	serve := func(target string, acceptEncoding string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest("GET", target, nil)
+		request.Header.Set("Accept-Encoding", acceptEncoding)
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	response := serve("/compressed/app.js", "gzip, deflate, br")
+	if response.Body.String() != "brotli bytes" || response.Header().Get("Content-Encoding") != "br" {
+		t.Fatalf("brotli sibling was not served, got: %s %v", response.Body.String(), response.Header())
+	}
+	if contentType := response.Header().Get("Content-Type"); contentType != "text/javascript; charset=utf-8" {
+		t.Fatalf("content type of the original file was not kept, got: %s", contentType)
+	}
+	if response.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatal("response with precompressed siblings does not vary on Accept-Encoding")
+	}
+	if response = serve("/compressed/app.js", "br;q=0.5, gzip"); response.Body.String() != "gzip bytes" {
+		t.Fatalf("preferred encoding was not served, got: %s", response.Body.String())
+	}
+	response = serve("/compressed/app.js", "br;q=0, identity")
+	if response.Body.String() != "console.log('plain')" || response.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("original file was not served, got: %s", response.Body.String())
+	}
+	if response = serve("/compressed/style.css", "br"); response.Header().Get("Vary") != "" || response.Body.String() != "body {}" {
+		t.Fatal("file without precompressed siblings was not served as is")
+	}
+}
+
+// countingFS counts how many times each file is opened.
+type countingFS struct {
+	fstest.MapFS
+	opens map[string]int
+}
+
+func (cfs countingFS) Open(name string) (fs.File, error) {
+	cfs.opens[name]++
+	return cfs.MapFS.Open(name)
+}
+
+func TestStaticETagCache(t *testing.T) {
+	assets := countingFS{MapFS: fstest.MapFS{"app.js": {Data: []byte("plain")}, "app.js.gz": {Data: []byte("gzip")}}, opens: map[string]int{}}
+	sd := staticDirOnFS(assets, StaticConfig{})
+	info, _ := fs.Stat(assets, "app.js")
+	etag := sd.etag("app.js", info)
+	for i := 0; i < 3; i++ {
+		if cached := sd.etag("app.js", info); cached != etag || assets.opens["app.js"] != 1 {
+			t.Fatalf("file was hashed %d times, got %q for %q", assets.opens["app.js"], cached, etag)
+		}
+	}
+	compressed, _ := fs.Stat(assets, "app.js.gz")
+	if sibling := sd.etag("app.js.gz", compressed); sibling == etag || sd.etag("app.js.gz", compressed) != sibling || assets.opens["app.js.gz"] != 1 {
+		t.Fatalf("precompressed sibling was not hashed once, got %q after %d opens", sibling, assets.opens["app.js.gz"])
+	}
+	assets.MapFS["app.js"].Data = []byte("changed")
+	info, _ = fs.Stat(assets, "app.js")
+	if changed := sd.etag("app.js", info); changed == etag {
+		t.Fatal("file whose size has changed was not hashed again")
+	}
+}
+
+func TestStaticDirPipeline(t *testing.T) {
+	controller := NewController("Assets", "/assets")
+	controller.AddRoutes(StaticFS("/files", assetsFS))
+	var requestedPaths []string
+	controller.AddRequestListeners(func(request RequestContext) RequestContext {
+		requestedPaths = append(requestedPaths, request.Url)
+		return request
+	})
+	controller.AddResponseListener(func(status Status) Status {
+		headers := http.Header{"X-Served-By": {"stgin"}}
+		for key, values := range status.Headers {
+			headers[key] = values
+		}
+		status.Headers = headers
+		return status
+	})
+	statusCodes := make(chan int, 1)
+	controller.AddAPIListeners(func(_ RequestContext, status Status) {
+		statusCodes <- status.StatusCode
+	})
+	server := &Server{Controllers: []*Controller{controller}, notFoundAction: notFoundDefaultAction}
+	handler := server.HttpHandler()
+
+	request := httptest.NewRequest("GET", "/assets/files/templates/mail.txt", nil)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Body.String() != "hello" || recorder.Header().Get("X-Served-By") != "stgin" {
+		t.Fatalf("static file was not served through response listeners, got: %s %v", recorder.Body.String(), recorder.Header())
+	}
+	if statusCode := <-statusCodes; statusCode != http.StatusOK {
+		t.Fatalf("api listener received the wrong status code: %d", statusCode)
+	}
+
+	request.Header.Set("If-None-Match", recorder.Header().Get("ETag"))
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if statusCode := <-statusCodes; recorder.Code != http.StatusNotModified || statusCode != http.StatusNotModified {
+		t.Fatalf("api listener did not receive the actual status code, got: %d, %d", recorder.Code, statusCode)
+	}
+	if len(requestedPaths) != 2 {
+		t.Fatalf("request listeners were not applied to static requests, got: %v", requestedPaths)
+	}
+}
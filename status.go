@@ -1,51 +1,92 @@
 package stgin
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"github.com/AminMal/slogger/colored"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 )
 
-var emptyHeaders http.Header = make(map[string][]string, 5)
-
 // Status is the return type of stgin APIs.
 // It represents an HTTP response with a status code, headers, body and cookies.
+// The With* methods copy the headers and cookies on write, so statuses can be shared and derived from concurrently
+// (i.e., by response listeners), without affecting each other.
 type Status struct {
 	StatusCode int
 	Entity     ResponseEntity
 	Headers    http.Header
 	cookies    []*http.Cookie
 	doneAt     time.Time
+	keepQuery  bool
 }
 
 func (status Status) DoneAt() time.Time { return status.doneAt }
 
 func (status Status) isRedirection() bool {
-	return status.StatusCode >= 300 && status.StatusCode < 400
+	return status.StatusCode >= 300 && status.StatusCode < 400 && status.StatusCode != http.StatusNotModified
+}
+
+// KeepQuery returns a new redirect Status, whose location keeps the query parameters of the request
+// (the parameters of the location itself take precedence).
+func (status Status) KeepQuery() Status {
+	status.keepQuery = true
+	return status
 }
 
 // WithCookies returns a new Status, appended the given cookies.
 func (status Status) WithCookies(cookies ...*http.Cookie) Status {
-	status.cookies = append(status.cookies, cookies...)
+	appended := make([]*http.Cookie, 0, len(status.cookies)+len(cookies))
+	status.cookies = append(append(appended, status.cookies...), cookies...)
 	return status
 }
 
-// WithHeaders returns a new Status, appended the given headers.
+// cloneHeaders copies the headers, so that the copy can be modified without affecting the statuses sharing them.
+func cloneHeaders(headers http.Header) http.Header {
+	cloned := make(http.Header, len(headers)+1)
+	for key, values := range headers {
+		cloned[key] = append([]string(nil), values...)
+	}
+	return cloned
+}
+
+// WithHeaders returns a new Status, having the values of the given headers (replacing the previous values of the same keys).
 func (status Status) WithHeaders(headers http.Header) Status {
-	for key, value := range headers {
-		status.Headers[key] = value
+	status.Headers = cloneHeaders(status.Headers)
+	for key, values := range headers {
+		status.Headers[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
 	}
 	return status
 }
 
-func write(status Status, rw http.ResponseWriter) {
-	bytes, contentType, marshallErr := marshall(status.Entity)
-	if marshallErr != nil {
-		_ = stginLogger.ErrorF("error while marshalling request entity:\n\t%v", fmt.Sprintf("%s%s%s", colored.RED, marshallErr.Error(), colored.ResetPrevColor))
-		panic(marshallErr)
-	}
+// WithHeader returns a new Status, having the given value as the header (replacing its previous values).
+func (status Status) WithHeader(key string, value string) Status {
+	status.Headers = cloneHeaders(status.Headers)
+	status.Headers.Set(key, value)
+	return status
+}
+
+// DeleteHeader returns a new Status, without the given header.
+func (status Status) DeleteHeader(key string) Status {
+	status.Headers = cloneHeaders(status.Headers)
+	status.Headers.Del(key)
+	return status
+}
+
+// WithCacheControl returns a new Status, having the given directives (i.e., "public", "max-age=3600") as its Cache-Control header.
+func (status Status) WithCacheControl(directives ...string) Status {
+	return status.WithHeader("Cache-Control", strings.Join(directives, ", "))
+}
+
+func writeHeaders(status Status, rw http.ResponseWriter) {
 	for key, values := range status.Headers {
 		for _, value := range values {
 			rw.Header().Add(key, value)
@@ -54,8 +95,21 @@ func write(status Status, rw http.ResponseWriter) {
 	for _, cookie := range status.cookies {
 		http.SetCookie(rw, cookie)
 	}
+}
+
+func write(status Status, rw http.ResponseWriter, codecs *CodecRegistry) {
+	bytes, contentType, marshallErr := marshall(status.Entity, codecs)
+	if marshallErr != nil {
+		_ = stginLogger.ErrorF("error while marshalling request entity:\n\t%v", fmt.Sprintf("%s%s%s", colored.RED, marshallErr.Error(), colored.ResetPrevColor))
+		panic(marshallErr)
+	}
+	writeHeaders(status, rw)
 	rw.Header().Set(contentTypeKey, contentType)
 	rw.WriteHeader(status.StatusCode)
+	// statuses like 204 and 304 do not allow a body at all
+	if len(bytes) == 0 {
+		return
+	}
 	_, err := rw.Write(bytes)
 	if err != nil {
 		stginLogger.ErrorF("error while writing response to client:\n\t%s", fmt.Sprintf("%s%s%s", colored.RED, err.Error(), colored.ResetPrevColor))
@@ -63,14 +117,95 @@ func write(status Status, rw http.ResponseWriter) {
 	}
 }
 
-func (status *Status) complete(request *http.Request, writer http.ResponseWriter) {
+// statusRecorder records the status code written by entities which write themselves into the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+}
+
+func (sr *statusRecorder) WriteHeader(statusCode int) {
+	if !sr.wroteHeader {
+		sr.statusCode, sr.wroteHeader = statusCode, true
+	}
+	sr.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (sr *statusRecorder) Write(bytes []byte) (int, error) {
+	sr.wroteHeader = true
+	return sr.ResponseWriter.Write(bytes)
+}
+
+// Flush keeps streaming entities able to flush the response.
+func (sr *statusRecorder) Flush() {
+	sr.wroteHeader = true
+	if flusher, isFlusher := sr.ResponseWriter.(http.Flusher); isFlusher {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets entities take over the connection (like websockets), if the underlying writer supports it.
+func (sr *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, isHijacker := sr.ResponseWriter.(http.Hijacker)
+	if !isHijacker {
+		return nil, nil, errors.New("the response writer does not support hijacking the connection")
+	}
+	sr.wroteHeader = true
+	return hijacker.Hijack()
+}
+
+// ReadFrom keeps the underlying writer's optimizations (like sendfile) available.
+func (sr *statusRecorder) ReadFrom(reader io.Reader) (int64, error) {
+	sr.wroteHeader = true
+	if readerFrom, isReaderFrom := sr.ResponseWriter.(io.ReaderFrom); isReaderFrom {
+		return readerFrom.ReadFrom(reader)
+	}
+	return io.Copy(sr.ResponseWriter, reader)
+}
+
+// complete writes the status into the response, errors of served entities are returned (see servedEntity).
+func (status *Status) complete(request *http.Request, writer http.ResponseWriter) error {
 	if status.isRedirection() {
-		location, _ := status.Entity.Bytes()
-		http.Redirect(writer, request, string(location), status.StatusCode)
-		return
+		location := status.Headers.Get("Location")
+		if location == "" && status.Entity != nil {
+			// redirects which are created manually may carry the location as their entity
+			bytes, _ := status.Entity.Bytes()
+			location = string(bytes)
+		}
+		writeHeaders(status.DeleteHeader("Location"), writer)
+		http.Redirect(writer, request, resolveLocation(request, location, status.keepQuery), status.StatusCode)
+	} else if served, isServed := status.Entity.(servedEntity); isServed {
+		writeHeaders(*status, writer)
+		recorder := &statusRecorder{ResponseWriter: writer, statusCode: status.StatusCode}
+		err := served.serve(recorder, request, status.StatusCode)
+		status.StatusCode = recorder.statusCode
+		return err
 	} else {
-		write(*status, writer)
+		write(*status, writer, codecsOf(request))
+	}
+	return nil
+}
+
+// resolveLocation resolves the relative locations against the request's url, and adds the request's query parameters
+// to the location if it should keep them.
+func resolveLocation(request *http.Request, location string, keepQuery bool) string {
+	target, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+	if target.Scheme == "" && target.Host == "" {
+		target = request.URL.ResolveReference(target)
+	}
+	if keepQuery && request.URL.RawQuery != "" {
+		query := target.Query()
+		for key, values := range request.URL.Query() {
+			if _, exists := query[key]; !exists {
+				query[key] = values
+			}
+		}
+		target.RawQuery = query.Encode()
 	}
+	return target.String()
 }
 
 // CreateResponse can be used in order to make responses that are not available in default functions in stgin.
@@ -79,7 +214,7 @@ func CreateResponse(statusCode int, body ResponseEntity) Status {
 	return Status{
 		StatusCode: statusCode,
 		Entity:     body,
-		Headers:    emptyHeaders,
+		Headers:    http.Header{},
 	}
 }
 
@@ -95,31 +230,79 @@ func Created(body ResponseEntity) Status {
 	return CreateResponse(http.StatusCreated, body)
 }
 
+// Accepted represents a basic http 202 response with the given body.
+func Accepted(body ResponseEntity) Status {
+	return CreateResponse(http.StatusAccepted, body)
+}
+
+// NonAuthoritativeInfo represents a basic http 203 response with the given body.
+func NonAuthoritativeInfo(body ResponseEntity) Status {
+	return CreateResponse(http.StatusNonAuthoritativeInfo, body)
+}
+
+// NoContent represents a basic http 204 response, which has no body.
+func NoContent() Status {
+	return CreateResponse(http.StatusNoContent, Empty())
+}
+
+// ResetContent represents a basic http 205 response, which has no body.
+func ResetContent() Status {
+	return CreateResponse(http.StatusResetContent, Empty())
+}
+
+// PartialContent represents a basic http 206 response with the given body, along with its Content-Range header
+// (i.e., "bytes 0-99/1000"). Files and readers which can seek already answer range requests with 206 themselves.
+func PartialContent(body ResponseEntity, contentRange string) Status {
+	return CreateResponse(http.StatusPartialContent, body).WithHeader("Content-Range", contentRange)
+}
+
 // ------------------
 // 3xx statuses here
 
+// Redirect represents an http redirect with the given status code (i.e., 303 see other) to the location,
+// which is set as the Location header. Relative locations are resolved against the url of the request
+// once the response is written, use KeepQuery to keep the query parameters of the request as well.
+func Redirect(statusCode int, location string) Status {
+	if statusCode < 300 || statusCode >= 400 || statusCode == http.StatusNotModified {
+		printStacktrace("")
+		panic(fmt.Sprintf("%d is not a redirect status code", statusCode))
+	}
+	return CreateResponse(statusCode, Empty()).WithHeader("Location", location)
+}
+
+// MultipleChoices represents a basic http 300 redirect to the given (preferred) location.
+func MultipleChoices(location string) Status {
+	return Redirect(http.StatusMultipleChoices, location)
+}
+
 // MovedPermanently represents a basic http 301 redirect to the given location.
 func MovedPermanently(location string) Status {
-	return Status{
-		StatusCode: http.StatusMovedPermanently,
-		Entity:     Text(location),
-	}
+	return Redirect(http.StatusMovedPermanently, location)
 }
 
 // Found represents a basic http 302 redirect to the given location.
 func Found(location string) Status {
-	return Status{
-		StatusCode: http.StatusFound,
-		Entity:     Text(location),
-	}
+	return Redirect(http.StatusFound, location)
+}
+
+// SeeOther represents a basic http 303 redirect to the given location, which is requested using GET.
+func SeeOther(location string) Status {
+	return Redirect(http.StatusSeeOther, location)
+}
+
+// NotModified represents a basic http 304 response, which has no body.
+func NotModified() Status {
+	return CreateResponse(http.StatusNotModified, Empty())
+}
+
+// TemporaryRedirect represents a basic http 307 redirect to the given location, which keeps the method and body.
+func TemporaryRedirect(location string) Status {
+	return Redirect(http.StatusTemporaryRedirect, location)
 }
 
 // PermanentRedirect represents a basic http 308 redirect to the given location.
 func PermanentRedirect(location string) Status {
-	return Status{
-		StatusCode: http.StatusPermanentRedirect,
-		Entity:     Text(location),
-	}
+	return Redirect(http.StatusPermanentRedirect, location)
 }
 
 // ------------------
@@ -135,6 +318,11 @@ func Unauthorized(body ResponseEntity) Status {
 	return CreateResponse(http.StatusUnauthorized, body)
 }
 
+// PaymentRequired represents a basic http 402 response with the given body.
+func PaymentRequired(body ResponseEntity) Status {
+	return CreateResponse(http.StatusPaymentRequired, body)
+}
+
 // Forbidden represents a basic http 403 response with the given body.
 func Forbidden(body ResponseEntity) Status {
 	return CreateResponse(http.StatusForbidden, body)
@@ -150,6 +338,102 @@ func MethodNotAllowed(body ResponseEntity) Status {
 	return CreateResponse(http.StatusMethodNotAllowed, body)
 }
 
+// NotAcceptable represents a basic http 406 response with the given body.
+func NotAcceptable(body ResponseEntity) Status {
+	return CreateResponse(http.StatusNotAcceptable, body)
+}
+
+// RequestTimeout represents a basic http 408 response with the given body.
+func RequestTimeout(body ResponseEntity) Status {
+	return CreateResponse(http.StatusRequestTimeout, body)
+}
+
+// Conflict represents a basic http 409 response with the given body.
+func Conflict(body ResponseEntity) Status {
+	return CreateResponse(http.StatusConflict, body)
+}
+
+// Gone represents a basic http 410 response with the given body.
+func Gone(body ResponseEntity) Status {
+	return CreateResponse(http.StatusGone, body)
+}
+
+// LengthRequired represents a basic http 411 response with the given body.
+func LengthRequired(body ResponseEntity) Status {
+	return CreateResponse(http.StatusLengthRequired, body)
+}
+
+// PreconditionFailed represents a basic http 412 response with the given body.
+func PreconditionFailed(body ResponseEntity) Status {
+	return CreateResponse(http.StatusPreconditionFailed, body)
+}
+
+// RequestEntityTooLarge represents a basic http 413 response with the given body.
+func RequestEntityTooLarge(body ResponseEntity) Status {
+	return CreateResponse(http.StatusRequestEntityTooLarge, body)
+}
+
+// RequestURITooLong represents a basic http 414 response with the given body.
+func RequestURITooLong(body ResponseEntity) Status {
+	return CreateResponse(http.StatusRequestURITooLong, body)
+}
+
+// UnsupportedMediaType represents a basic http 415 response with the given body.
+func UnsupportedMediaType(body ResponseEntity) Status {
+	return CreateResponse(http.StatusUnsupportedMediaType, body)
+}
+
+// RequestedRangeNotSatisfiable represents a basic http 416 response with the given body.
+func RequestedRangeNotSatisfiable(body ResponseEntity) Status {
+	return CreateResponse(http.StatusRequestedRangeNotSatisfiable, body)
+}
+
+// ExpectationFailed represents a basic http 417 response with the given body.
+func ExpectationFailed(body ResponseEntity) Status {
+	return CreateResponse(http.StatusExpectationFailed, body)
+}
+
+// UnprocessableEntity represents a basic http 422 response with the given body.
+func UnprocessableEntity(body ResponseEntity) Status {
+	return CreateResponse(http.StatusUnprocessableEntity, body)
+}
+
+// Locked represents a basic http 423 response with the given body.
+func Locked(body ResponseEntity) Status {
+	return CreateResponse(http.StatusLocked, body)
+}
+
+// TooEarly represents a basic http 425 response with the given body.
+func TooEarly(body ResponseEntity) Status {
+	return CreateResponse(http.StatusTooEarly, body)
+}
+
+// UpgradeRequired represents a basic http 426 response with the given body.
+func UpgradeRequired(body ResponseEntity) Status {
+	return CreateResponse(http.StatusUpgradeRequired, body)
+}
+
+// PreconditionRequired represents a basic http 428 response with the given body.
+func PreconditionRequired(body ResponseEntity) Status {
+	return CreateResponse(http.StatusPreconditionRequired, body)
+}
+
+// TooManyRequests represents a basic http 429 response with the given body, which tells the client
+// to retry after the given duration (using the Retry-After header), if it's not zero.
+func TooManyRequests(body ResponseEntity, retryAfter time.Duration) Status {
+	return CreateResponse(http.StatusTooManyRequests, body).withRetryAfter(retryAfter)
+}
+
+// RequestHeaderFieldsTooLarge represents a basic http 431 response with the given body.
+func RequestHeaderFieldsTooLarge(body ResponseEntity) Status {
+	return CreateResponse(http.StatusRequestHeaderFieldsTooLarge, body)
+}
+
+// UnavailableForLegalReasons represents a basic http 451 response with the given body.
+func UnavailableForLegalReasons(body ResponseEntity) Status {
+	return CreateResponse(http.StatusUnavailableForLegalReasons, body)
+}
+
 // ------------------
 // 5xx statuses here
 
@@ -158,21 +442,77 @@ func InternalServerError(body ResponseEntity) Status {
 	return CreateResponse(http.StatusInternalServerError, body)
 }
 
+// NotImplemented represents a basic http 501 response with the given body.
+func NotImplemented(body ResponseEntity) Status {
+	return CreateResponse(http.StatusNotImplemented, body)
+}
+
+// BadGateway represents a basic http 502 response with the given body.
+func BadGateway(body ResponseEntity) Status {
+	return CreateResponse(http.StatusBadGateway, body)
+}
+
+// ServiceUnavailable represents a basic http 503 response with the given body, which tells the client
+// to retry after the given duration (using the Retry-After header), if it's not zero.
+func ServiceUnavailable(body ResponseEntity, retryAfter time.Duration) Status {
+	return CreateResponse(http.StatusServiceUnavailable, body).withRetryAfter(retryAfter)
+}
+
+// GatewayTimeout represents a basic http 504 response with the given body.
+func GatewayTimeout(body ResponseEntity) Status {
+	return CreateResponse(http.StatusGatewayTimeout, body)
+}
+
+// HTTPVersionNotSupported represents a basic http 505 response with the given body.
+func HTTPVersionNotSupported(body ResponseEntity) Status {
+	return CreateResponse(http.StatusHTTPVersionNotSupported, body)
+}
+
+// InsufficientStorage represents a basic http 507 response with the given body.
+func InsufficientStorage(body ResponseEntity) Status {
+	return CreateResponse(http.StatusInsufficientStorage, body)
+}
+
+// withRetryAfter sets the Retry-After header in seconds (rounded up), zero durations are omitted.
+func (status Status) withRetryAfter(retryAfter time.Duration) Status {
+	if retryAfter <= 0 {
+		return status
+	}
+	seconds := (retryAfter + time.Second - 1) / time.Second
+	return status.WithHeader("Retry-After", strconv.FormatInt(int64(seconds), 10))
+}
+
 //------------------
 
 // File is used to return a file itself as an HTTP response.
+// The file is streamed to the client when the response is written, supporting range and conditional requests
+// (Range, If-Modified-Since, If-None-Match, ...).
 // If the file is not found, it returns 404 not found to the client.
 // If there are issues reading file or anything else related, 500 internal server error is returned to the client.
 func File(path string) Status {
-	file := fileContent{path}
-	_, err := file.Bytes()
+	info, err := os.Stat(path)
+	return fileStatus(fileContent{path: path}, info, err)
+}
+
+// FileFrom is used to return a file inside the given file system (like an embed.FS) as an HTTP response.
+// Just like File, it returns 404 not found if the file does not exist, and 500 internal server error
+// if there are issues reading the file.
+func FileFrom(fsys fs.FS, path string) Status {
+	info, err := fs.Stat(fsys, path)
+	return fileStatus(fileContent{fsys: fsys, path: path}, info, err)
+}
+
+func fileStatus(file fileContent, info fs.FileInfo, err error) Status {
 	if err != nil {
 		_ = stginLogger.Colored(colored.RED).ErrorF("error reading file '%s': %s", file.path, err.Error())
-		if strings.Contains(err.Error(), "no such file or directory") {
-			return NotFound(Text("404 not found"))
+		if os.IsNotExist(err) {
+			return NotFound(deferredProblem{http.StatusNotFound, "file not found"})
 		} else {
-			return InternalServerError(Text("internal server error"))
+			return InternalServerError(deferredProblem{http.StatusInternalServerError, "internal server error"})
 		}
+	} else if info.IsDir() {
+		_ = stginLogger.Colored(colored.RED).ErrorF("error reading file '%s': is a directory", file.path)
+		return NotFound(deferredProblem{http.StatusNotFound, "file not found"})
 	} else {
 		return Ok(file)
 	}
@@ -0,0 +1,136 @@
+package stgin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStatus_CopyOnWrite(t *testing.T) {
+	if status := Ok(Text("first")).WithHeaders(http.Header{"x-leak": {"1"}}); status.Headers.Get("X-Leak") != "1" {
+		t.Fatalf("header was not added, got %v", status.Headers)
+	}
+	if status := Ok(Text("second")); len(status.Headers) != 0 {
+		t.Fatalf("headers leaked into another response: %v", status.Headers)
+	}
+
+	base := Ok(Text("base")).WithHeader("X-Base", "1").WithCacheControl("public", "max-age=60")
+	derived := base.WithHeader("X-Base", "2").DeleteHeader("Cache-Control")
+	if base.Headers.Get("X-Base") != "1" || base.Headers.Get("Cache-Control") != "public, max-age=60" {
+		t.Fatalf("the original status was modified: %v", base.Headers)
+	}
+	if derived.Headers.Get("X-Base") != "2" || derived.Headers.Get("Cache-Control") != "" {
+		t.Fatalf("unexpected derived headers: %v", derived.Headers)
+	}
+
+	for _, redirect := range []Status{MovedPermanently("/a"), Found("/a"), PermanentRedirect("/a"), {StatusCode: http.StatusFound}} {
+		if status := redirect.WithHeaders(http.Header{"X-Reason": {"moved"}}); status.Headers.Get("X-Reason") != "moved" {
+			t.Fatalf("headers were not added to the redirect, got %v", status.Headers)
+		}
+	}
+
+	withCookie := Ok(Text("cookies")).WithCookies(&http.Cookie{Name: "a"}, &http.Cookie{Name: "b"})
+	first, second := withCookie.WithCookies(&http.Cookie{Name: "first"}), withCookie.WithCookies(&http.Cookie{Name: "second"})
+	if len(withCookie.cookies) != 2 || first.cookies[2].Name != "first" || second.cookies[2].Name != "second" {
+		t.Fatalf("derived statuses share their cookies")
+	}
+}
+
+func TestStatus_ConcurrentListeners(t *testing.T) {
+	shared := Ok(Text("shared")).WithHeader("X-Shared", "1")
+	var wg sync.WaitGroup
+	results := make([]Status, 32)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = shared.WithHeader("X-Index", strconv.Itoa(i)).WithCookies(&http.Cookie{Name: strconv.Itoa(i)})
+		}(i)
+	}
+	wg.Wait()
+	for i, result := range results {
+		if result.Headers.Get("X-Index") != strconv.Itoa(i) || result.Headers.Get("X-Shared") != "1" || len(result.cookies) != 1 {
+			t.Fatalf("unexpected status %d: %v", i, result.Headers)
+		}
+	}
+	if shared.Headers.Get("X-Index") != "" {
+		t.Fatalf("the shared status was modified: %v", shared.Headers)
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	cases := []struct {
+		status   Status
+		target   string
+		code     int
+		location string
+	}{
+		{MovedPermanently("/home"), "/old", http.StatusMovedPermanently, "/home"},
+		{SeeOther("created/12"), "/users/", http.StatusSeeOther, "/users/created/12"},
+		{TemporaryRedirect("../login"), "/app/users/list?page=2", http.StatusTemporaryRedirect, "/app/login"},
+		{Found("/search?q=go").KeepQuery(), "/find?q=old&page=2", http.StatusFound, "/search?page=2&q=go"},
+		{PermanentRedirect("https://example.com/a"), "/a?x=1", http.StatusPermanentRedirect, "https://example.com/a"},
+		{Redirect(http.StatusFound, "next").KeepQuery(), "/steps/first?token=t", http.StatusFound, "/steps/next?token=t"},
+		{CreateResponse(http.StatusFound, Text("/legacy")), "/", http.StatusFound, "/legacy"},
+	}
+	for _, c := range cases {
+		if c.status.Headers.Get("Location") == "" && c.location != "/legacy" {
+			t.Errorf("location of the redirect to %q was not set explicitly", c.location)
+		}
+		controller := NewController("Redirects", "")
+		status := c.status.WithCookies(&http.Cookie{Name: "session", Value: "1"})
+		path := strings.SplitN(c.target, "?", 2)[0]
+		controller.AddRoutes(GET(path, func(RequestContext) Status { return status }))
+		server := &Server{Controllers: []*Controller{controller}, notFoundAction: notFoundDefaultAction}
+		recorder := httptest.NewRecorder()
+		server.HttpHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, c.target, nil))
+		if recorder.Code != c.code || recorder.Header().Get("Location") != c.location {
+			t.Errorf("expected %d to %q for %s, got %d to %q", c.code, c.location, c.target, recorder.Code, recorder.Header().Get("Location"))
+		}
+		if len(recorder.Header().Values("Set-Cookie")) != 1 {
+			t.Errorf("cookies of the redirect were not written for %s", c.target)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected a panic for a non-redirect status code")
+		}
+	}()
+	Redirect(http.StatusOK, "/")
+}
+
+func TestStatusCatalog(t *testing.T) {
+	if status := TooManyRequests(Text("slow down"), 1500*time.Millisecond); status.StatusCode != 429 || status.Headers.Get("Retry-After") != "2" {
+		t.Fatalf("unexpected too many requests status: %d %v", status.StatusCode, status.Headers)
+	}
+	if status := ServiceUnavailable(Text("maintenance"), 0); status.StatusCode != 503 || status.Headers.Get("Retry-After") != "" {
+		t.Fatalf("unexpected service unavailable status: %d %v", status.StatusCode, status.Headers)
+	}
+	if status := PartialContent(Text("abc"), "bytes 0-2/10"); status.StatusCode != 206 || status.Headers.Get("Content-Range") != "bytes 0-2/10" {
+		t.Fatalf("unexpected partial content status: %d %v", status.StatusCode, status.Headers)
+	}
+
+	controller := NewController("Catalog", "")
+	controller.AddRoutes(
+		GET("/no-content", func(RequestContext) Status { return NoContent() }),
+		GET("/not-modified", func(RequestContext) Status { return NotModified().WithHeader("ETag", `"1"`) }),
+		POST("/accepted", func(RequestContext) Status { return Accepted(Text("queued")) }),
+	)
+	server := &Server{Controllers: []*Controller{controller}, notFoundAction: notFoundDefaultAction}
+	for path, expected := range map[string]int{"/no-content": 204, "/not-modified": 304, "/accepted": 202} {
+		method := http.MethodGet
+		if path == "/accepted" {
+			method = http.MethodPost
+		}
+		recorder := httptest.NewRecorder()
+		server.HttpHandler().ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+		if recorder.Code != expected || (expected != 202 && recorder.Body.Len() != 0) {
+			t.Errorf("expected %d for %s, got %d %q", expected, path, recorder.Code, recorder.Body.String())
+		}
+	}
+}
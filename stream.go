@@ -0,0 +1,264 @@
+package stgin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// streamEntity is a ResponseEntity which is written into the response while it's being produced,
+// instead of being built in memory first.
+type streamEntity struct {
+	contentType string
+	stream      func(writer io.Writer) error
+}
+
+// Stream creates an entity which is written by the given function, directly into the response.
+// The response is sent using chunked transfer encoding, and everything written is flushed to the client immediately.
+// If the function fails before writing anything, the error is handed to the server's error handler just like panics,
+// otherwise the status code and headers are already sent, so the connection is aborted, and the client sees an incomplete response.
+// Writes fail with the request context's error once the client disconnects, which should be returned by the function.
+// Note that streams are written after the API is done, so response listeners cannot see the content,
+// and interrupts (like timeouts) do not apply to them.
+func Stream(contentType string, stream func(writer io.Writer) error) ResponseEntity {
+	return streamEntity{contentType: contentType, stream: stream}
+}
+
+// Reader creates an entity which streams the content of the reader into the response, see Stream.
+// The reader is closed after it's written if it's an io.Closer.
+func Reader(contentType string, reader io.Reader) ResponseEntity {
+	return streamEntity{contentType: contentType, stream: func(writer io.Writer) error {
+		if closer, isCloser := reader.(io.Closer); isCloser {
+			defer closer.Close()
+		}
+		_, err := io.Copy(writer, reader)
+		return err
+	}}
+}
+
+func (se streamEntity) ContentType() string {
+	if se.contentType == "" {
+		return octetStream
+	}
+	return se.contentType
+}
+
+// Bytes runs the stream into memory, this is only used if the entity is not written into a response directly.
+func (se streamEntity) Bytes() ([]byte, error) {
+	var buffer bytes.Buffer
+	err := se.stream(&buffer)
+	return buffer.Bytes(), err
+}
+
+func (se streamEntity) serve(writer http.ResponseWriter, request *http.Request, statusCode int) error {
+	if writer.Header().Get(contentTypeKey) == "" {
+		writer.Header().Set(contentTypeKey, se.ContentType())
+	}
+	// the length is unknown, so the response is chunked
+	writer.Header().Del("Content-Length")
+	if request.Method == http.MethodHead {
+		writer.WriteHeader(statusCode)
+		return nil
+	}
+	flusher := &flushWriter{writer: writer, statusCode: statusCode, ctx: request.Context()}
+	err := se.stream(flusher)
+	if err == nil {
+		flusher.writeHeader()
+		return nil
+	}
+	if request.Context().Err() != nil {
+		// the client is gone, there's nobody to report the error to
+		return nil
+	}
+	if !flusher.started {
+		return err
+	}
+	return abortedResponse{err: err}
+}
+
+// flushWriter writes the status code before the first write, and flushes each write to the client.
+type flushWriter struct {
+	writer     http.ResponseWriter
+	statusCode int
+	ctx        context.Context
+	started    bool
+}
+
+func (fw *flushWriter) writeHeader() {
+	if !fw.started {
+		fw.started = true
+		fw.writer.WriteHeader(fw.statusCode)
+	}
+}
+
+func (fw *flushWriter) Write(content []byte) (int, error) {
+	if err := fw.ctx.Err(); err != nil {
+		return 0, err
+	}
+	fw.writeHeader()
+	written, err := fw.writer.Write(content)
+	if err != nil {
+		return written, err
+	}
+	if flusher, isFlusher := fw.writer.(http.Flusher); isFlusher {
+		flusher.Flush()
+	}
+	return written, nil
+}
+
+// abortedResponse is the error of entities which failed after some of the response was sent to the client.
+type abortedResponse struct {
+	err error
+}
+
+func (ar abortedResponse) Error() string { return "response aborted: " + ar.err.Error() }
+func (ar abortedResponse) Unwrap() error { return ar.err }
+
+// streamErrorTrailer is the trailer reporting the errors which happen in the middle of NDJSON streams.
+const streamErrorTrailer = "Stream-Error"
+
+// Iterator produces the items of streamed entities. It calls yield with the items in order, and must stop and return
+// the error once yield fails (i.e., the client is disconnected). Errors returned by the iterator are reported to the client.
+type Iterator[T any] func(yield func(item T) error) error
+
+// ChannelIterator iterates over the items received from the channel until it's closed.
+// Note that the channel is not drained if the iteration stops early (i.e., the client is disconnected),
+// so the sender should also stop once the request's context is done.
+func ChannelIterator[T any](items <-chan T) Iterator[T] {
+	return func(yield func(item T) error) error {
+		for item := range items {
+			if err := yield(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// jsonStreamEntity encodes the items of an iterator one at a time, as a JSON array or as newline-delimited JSON.
+type jsonStreamEntity struct {
+	contentType string
+	ndjson      bool
+	iterate     Iterator[any]
+}
+
+// JsonStream streams the items received from the channel as a JSON array, see JsonStreamOf.
+func JsonStream[T any](items <-chan T) ResponseEntity {
+	return JsonStreamOf(ChannelIterator(items))
+}
+
+// JsonStreamOf streams the items of the iterator as a JSON array, each item is encoded and flushed to the client
+// as soon as it's produced. If the iteration fails before producing any items, the error is handed to the server's
+// error handler. Otherwise, the connection is aborted, so that the client never receives a valid JSON array.
+func JsonStreamOf[T any](iterate Iterator[T]) ResponseEntity {
+	return jsonStreamEntity{contentType: applicationJson, iterate: anyIterator(iterate)}
+}
+
+// NDJSON streams the items of the iterator as newline-delimited JSON (one JSON value in each line), each item is
+// encoded and flushed to the client as soon as it's produced. If the iteration fails before producing any items,
+// the error is handed to the server's error handler. Otherwise, the stream is terminated with an error record
+// like {"error":"internal server error"}, and the same message is sent in the Stream-Error trailer.
+// The messages of errors implementing StatusCoder (like HttpError) are sent as is.
+func NDJSON[T any](iterate Iterator[T]) ResponseEntity {
+	return jsonStreamEntity{contentType: "application/x-ndjson", ndjson: true, iterate: anyIterator(iterate)}
+}
+
+func anyIterator[T any](iterate Iterator[T]) Iterator[any] {
+	return func(yield func(item any) error) error {
+		return iterate(func(item T) error { return yield(item) })
+	}
+}
+
+func (je jsonStreamEntity) ContentType() string {
+	return je.contentType
+}
+
+// Bytes encodes all the items into memory, this is only used if the entity is not written into a response directly.
+func (je jsonStreamEntity) Bytes() ([]byte, error) {
+	var buffer bytes.Buffer
+	err := je.write(&buffer, DefaultCodecs.lookup(applicationJson))
+	return buffer.Bytes(), err
+}
+
+func (je jsonStreamEntity) write(writer io.Writer, codec Codec) error {
+	var count int
+	err := je.iterate(func(item any) error {
+		encoded, err := codec.Marshal(item)
+		if err != nil {
+			return err
+		}
+		var chunk []byte
+		switch {
+		case je.ndjson:
+			// each record must be in a single line, even if the codec indents the values
+			var compacted bytes.Buffer
+			if err = json.Compact(&compacted, encoded); err != nil {
+				return err
+			}
+			chunk = append(compacted.Bytes(), '\n')
+		case count == 0:
+			chunk = append([]byte{'['}, encoded...)
+		default:
+			chunk = append([]byte{','}, encoded...)
+		}
+		count++
+		_, err = writer.Write(chunk)
+		return err
+	})
+	if err != nil || je.ndjson {
+		return err
+	}
+	if count == 0 {
+		_, err = writer.Write([]byte("[]"))
+	} else {
+		_, err = writer.Write([]byte("]"))
+	}
+	return err
+}
+
+// streamErrorMessage is the message of mid-stream errors sent to the clients, which does not expose unknown errors.
+func streamErrorMessage(err error) string {
+	var coder StatusCoder
+	if errors.As(err, &coder) {
+		return err.Error()
+	}
+	return "internal server error"
+}
+
+func (je jsonStreamEntity) serve(writer http.ResponseWriter, request *http.Request, statusCode int) error {
+	if writer.Header().Get(contentTypeKey) == "" {
+		writer.Header().Set(contentTypeKey, je.ContentType())
+	}
+	writer.Header().Del("Content-Length")
+	if je.ndjson {
+		writer.Header().Set("Trailer", streamErrorTrailer)
+	}
+	if request.Method == http.MethodHead {
+		writer.WriteHeader(statusCode)
+		return nil
+	}
+	flusher := &flushWriter{writer: writer, statusCode: statusCode, ctx: request.Context()}
+	err := je.write(flusher, codecsOf(request).lookup(applicationJson))
+	if err == nil {
+		flusher.writeHeader()
+		return nil
+	}
+	if request.Context().Err() != nil {
+		return nil
+	}
+	if !flusher.started {
+		return err
+	}
+	if !je.ndjson {
+		return abortedResponse{err: err}
+	}
+	_ = stginLogger.ErrorF("%s -> %s\t\t| stream terminated with error:\n\t%s", request.Method, request.URL.Path, err.Error())
+	message := streamErrorMessage(err)
+	record, _ := json.Marshal(map[string]string{"error": message})
+	_, _ = flusher.Write(append(record, '\n'))
+	writer.Header().Set(streamErrorTrailer, message)
+	return nil
+}
@@ -0,0 +1,206 @@
+package stgin
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func streamServer(controller *Controller) *httptest.Server {
+	server := &Server{Controllers: []*Controller{controller}, notFoundAction: notFoundDefaultAction, errorAction: errorAction}
+	return httptest.NewServer(server.HttpHandler())
+}
+
+func TestStream(t *testing.T) {
+	proceed := make(chan struct{})
+	controller := NewController("Streams", "")
+	controller.AddRoutes(
+		GET("/events", func(RequestContext) Status {
+			return Ok(Stream("text/plain", func(writer io.Writer) error {
+				_, _ = fmt.Fprintln(writer, "first")
+				<-proceed
+				_, err := fmt.Fprintln(writer, "second")
+				return err
+			}))
+		}),
+		GET("/reader", func(RequestContext) Status {
+			return Ok(Reader("text/csv", strings.NewReader("id,name\n1,john\n")))
+		}),
+	)
+	server := streamServer(controller)
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if len(response.TransferEncoding) == 0 || response.TransferEncoding[0] != "chunked" {
+		t.Fatalf("stream was not chunked, got: %v", response.TransferEncoding)
+	}
+	lines := bufio.NewReader(response.Body)
+	if line, _ := lines.ReadString('\n'); line != "first\n" {
+		t.Fatalf("first chunk was not flushed, got: %q", line)
+	}
+	close(proceed)
+	if line, _ := lines.ReadString('\n'); line != "second\n" {
+		t.Fatalf("second chunk was not received, got: %q", line)
+	}
+
+	response, err = http.Get(server.URL + "/reader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	if string(body) != "id,name\n1,john\n" || response.Header.Get("Content-Type") != "text/csv" {
+		t.Fatalf("reader was not streamed, got: %s %v", string(body), response.Header)
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	statusCodes := make(chan int, 2)
+	controller := NewController("Streams", "")
+	controller.AddAPIListeners(func(_ RequestContext, status Status) {
+		statusCodes <- status.StatusCode
+	})
+	controller.AddRoutes(
+		GET("/early", func(RequestContext) Status {
+			return Ok(Stream("text/plain", func(io.Writer) error { return errors.New("database is down") }))
+		}),
+		GET("/midway", func(RequestContext) Status {
+			return Ok(Stream("text/plain", func(writer io.Writer) error {
+				_, _ = writer.Write([]byte("partial"))
+				return errors.New("database is down")
+			}))
+		}),
+	)
+	server := streamServer(controller)
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/early")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusInternalServerError || response.Header.Get("Content-Type") != applicationProblemJson {
+		t.Fatalf("stream failing before writing was not handled by the error handler, got: %d %v", response.StatusCode, response.Header)
+	}
+	if statusCode := <-statusCodes; statusCode != http.StatusInternalServerError {
+		t.Fatalf("api listener received the wrong status code: %d", statusCode)
+	}
+
+	response, err = http.Get(server.URL + "/midway")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = io.ReadAll(response.Body); err == nil {
+		t.Fatal("stream failing after writing was not aborted")
+	}
+	if statusCode := <-statusCodes; statusCode != http.StatusOK {
+		t.Fatalf("api listener received the wrong status code: %d", statusCode)
+	}
+}
+
+func TestStreamClientDisconnect(t *testing.T) {
+	streamErr := make(chan error, 1)
+	controller := NewController("Streams", "")
+	controller.AddRoutes(GET("/ticks", func(RequestContext) Status {
+		return Ok(Stream("text/plain", func(writer io.Writer) error {
+			for {
+				if _, err := writer.Write([]byte("tick\n")); err != nil {
+					streamErr <- err
+					return err
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+		}))
+	}))
+	server := streamServer(controller)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	request, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/ticks", nil)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = bufio.NewReader(response.Body).ReadString('\n')
+	cancel()
+	select {
+	case <-streamErr:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream did not stop after the client disconnected")
+	}
+}
+
+type exportedRow struct {
+	ID int `json:"id"`
+}
+
+func TestJsonStreams(t *testing.T) {
+	rows := func(count int, failure error) Iterator[exportedRow] {
+		return func(yield func(exportedRow) error) error {
+			for i := 1; i <= count; i++ {
+				if err := yield(exportedRow{ID: i}); err != nil {
+					return err
+				}
+			}
+			return failure
+		}
+	}
+	controller := NewController("Exports", "")
+	controller.AddRoutes(
+		GET("/array", func(RequestContext) Status {
+			items := make(chan exportedRow, 3)
+			items <- exportedRow{ID: 1}
+			items <- exportedRow{ID: 2}
+			close(items)
+			return Ok(JsonStream(items))
+		}),
+		GET("/empty", func(RequestContext) Status { return Ok(JsonStreamOf(rows(0, nil))) }),
+		GET("/broken-array", func(RequestContext) Status { return Ok(JsonStreamOf(rows(2, errors.New("disk failure")))) }),
+		GET("/ndjson", func(RequestContext) Status { return Ok(NDJSON(rows(2, nil))) }),
+		GET("/broken-ndjson", func(RequestContext) Status {
+			return Ok(NDJSON(rows(2, HttpError(http.StatusServiceUnavailable, "replica is gone"))))
+		}),
+		GET("/failed-ndjson", func(RequestContext) Status { return Ok(NDJSON(rows(0, errors.New("disk failure")))) }),
+	)
+	server := streamServer(controller)
+	defer server.Close()
+	get := func(path string) (*http.Response, string, error) {
+		response, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		body, err := io.ReadAll(response.Body)
+		return response, string(body), err
+	}
+
+	if response, body, _ := get("/array"); body != `[{"id":1},{"id":2}]` || response.Header.Get("Content-Type") != applicationJson {
+		t.Fatalf("channel was not streamed as a json array, got: %s %v", body, response.Header)
+	}
+	if _, body, _ := get("/empty"); body != "[]" {
+		t.Fatalf("empty iterator was not streamed as an empty array, got: %s", body)
+	}
+	if _, body, err := get("/broken-array"); err == nil {
+		t.Fatalf("broken json array stream was not aborted, got: %s", body)
+	}
+	response, body, _ := get("/ndjson")
+	if body != "{\"id\":1}\n{\"id\":2}\n" || response.Header.Get("Content-Type") != "application/x-ndjson" || response.Trailer.Get("Stream-Error") != "" {
+		t.Fatalf("iterator was not streamed as ndjson, got: %s %v", body, response.Header)
+	}
+	response, body, _ = get("/broken-ndjson")
+	if body != "{\"id\":1}\n{\"id\":2}\n{\"error\":\"replica is gone\"}\n" || response.Trailer.Get("Stream-Error") != "replica is gone" {
+		t.Fatalf("mid-stream error was not reported, got: %s %v", body, response.Trailer)
+	}
+	if response, _, _ = get("/failed-ndjson"); response.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("stream failing before the first item was not handled by the error handler, got: %d", response.StatusCode)
+	}
+}
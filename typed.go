@@ -0,0 +1,103 @@
+package stgin
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+)
+
+// StatusCoder can be implemented by errors returned from typed handlers, to decide the status code of the response.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+type httpError struct {
+	statusCode int
+	message    string
+}
+
+func (he httpError) Error() string   { return he.message }
+func (he httpError) StatusCode() int { return he.statusCode }
+
+// HttpError creates an error which completes typed handlers with the given status code and message.
+func HttpError(statusCode int, message string) error {
+	return httpError{statusCode: statusCode, message: message}
+}
+
+// bindTyped fills a new Req from the request; structs are bound using SafeBind,
+// and other types are decoded from the body based on the request's content type.
+func bindTyped[Req any](request RequestContext) (Req, error) {
+	var req Req
+	tpe := reflect.TypeOf(&req).Elem()
+	target := reflect.ValueOf(&req)
+	if tpe.Kind() == reflect.Ptr {
+		target.Elem().Set(reflect.New(tpe.Elem()))
+		target = target.Elem()
+		tpe = tpe.Elem()
+	}
+	if tpe.Kind() == reflect.Struct {
+		if tpe.NumField() == 0 {
+			return req, nil
+		}
+		return req, request.SafeBind(target.Interface())
+	}
+	if err := request.bindBody(target.Interface()); err != nil {
+		return req, err
+	}
+	return req, validateWithNames(target.Interface(), "json", "xml")
+}
+
+// typedErrorStatus maps the errors of typed handlers into statuses.
+// Errors which are not known to stgin are panicked, so that the server's error handler decides about them.
+func typedErrorStatus(request RequestContext, err error) Status {
+	var bindingErr BindingError
+	var validationErr ValidationError
+	var coder StatusCoder
+	switch {
+	case errors.As(err, &bindingErr):
+		return invalidFieldsResponse(request, "could not bind request values", bindingErr.Errors)
+	case errors.As(err, &validationErr):
+		return invalidFieldsResponse(request, "request validation failed", validationErr.Errors)
+	case errors.As(err, &coder):
+		return failureResponse(request, coder.StatusCode(), err.Error())
+	}
+	var parseErr ParseError
+	var malformedErr MalformedRequestContext
+	var queryErr QueryError
+	if errors.As(err, &parseErr) || errors.As(err, &malformedErr) || errors.As(err, &queryErr) {
+		return failureResponse(request, http.StatusBadRequest, err.Error())
+	}
+	panic(err)
+}
+
+// Typed adapts a function which receives a typed request object and returns a typed response, into an API.
+// The request object is bound and validated using Bind (or decoded from the body if it's not a struct),
+// and the response is negotiated based on the request's Accept header, just like Negotiate.
+// If the response is a Status or a ResponseEntity, it is used as is.
+// Returned errors are mapped into statuses; binding and validation failures become 400 bad request,
+// errors implementing StatusCoder (like HttpError) use their own status code,
+// and other errors are handed to the server's error handler.
+func Typed[Req any, Resp any](handler func(RequestContext, Req) (Resp, error)) API {
+	if handler == nil {
+		printStacktrace("")
+		panic("cannot use nil as a typed handler")
+	}
+	return func(request RequestContext) Status {
+		req, err := bindTyped[Req](request)
+		if err != nil {
+			return typedErrorStatus(request, err)
+		}
+		resp, err := handler(request, req)
+		if err != nil {
+			return typedErrorStatus(request, err)
+		}
+		switch result := any(resp).(type) {
+		case Status:
+			return result
+		case ResponseEntity:
+			return Ok(result)
+		default:
+			return Ok(Negotiate(resp))
+		}
+	}
+}
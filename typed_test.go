@@ -0,0 +1,89 @@
+package stgin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type greetRequest struct {
+	Name  string `json:"name" validate:"required"`
+	Times int    `qp:"times,default=1" validate:"max=3"`
+}
+
+type greetResponse struct {
+	Greeting string `json:"greeting" xml:"greeting"`
+}
+
+func greet(_ RequestContext, req greetRequest) (greetResponse, error) {
+	if req.Name == "nobody" {
+		return greetResponse{}, HttpError(http.StatusNotFound, "nobody is not here")
+	}
+	return greetResponse{Greeting: strings.Repeat("hello "+req.Name+"!", req.Times)}, nil
+}
+
+func executeTyped(api API, target string, body string, accept string) Status {
+	req := httptest.NewRequest("POST", target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", accept)
+	return api(requestContextFromHttpRequest(req, nil, Params{}))
+}
+
+func TestTyped(t *testing.T) {
+	api := Typed(greet)
+	status := executeTyped(api, "/greet?times=2", `{"name": "John"}`, "application/json")
+	if status.StatusCode != http.StatusOK || status.Entity.ContentType() != applicationJson {
+		t.Fatalf("unexpected typed handler result: %d %s", status.StatusCode, status.Entity.ContentType())
+	}
+	bytes, _ := status.Entity.Bytes()
+	var response greetResponse
+	_ = json.Unmarshal(bytes, &response)
+	if response.Greeting != "hello John!hello John!" {
+		t.Fatalf("typed handler did not receive the bound request, got: %s", response.Greeting)
+	}
+}
+
+func TestTypedNegotiation(t *testing.T) {
+	controller := NewController("Typed", "")
+	controller.AddRoutes(POST("/greet", Typed(greet)))
+	registry := NewCodecRegistry()
+	_ = registry.Register("application/vnd.greeting+json", JSONCodec{Indent: "  "})
+	controller.SetCodecs(registry)
+	server := &Server{Controllers: []*Controller{controller}, notFoundAction: notFoundDefaultAction}
+	cases := map[string]struct {
+		statusCode  int
+		contentType string
+	}{
+		"text/html, application/xml;q=0.9":        {http.StatusOK, applicationXml},
+		"application/json;q=0, application/xml":   {http.StatusOK, applicationXml},
+		"application/xml;q=0.5, application/json": {http.StatusOK, applicationJson},
+		"application/vnd.greeting+json":           {http.StatusOK, "application/vnd.greeting+json"},
+		"text/html":                               {http.StatusNotAcceptable, applicationProblemJson},
+	}
+	for accept, expected := range cases {
+		request := httptest.NewRequest(http.MethodPost, "/greet", strings.NewReader(`{"name": "John"}`))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Accept", accept)
+		recorder := httptest.NewRecorder()
+		server.HttpHandler().ServeHTTP(recorder, request)
+		if recorder.Code != expected.statusCode || recorder.Header().Get("Content-Type") != expected.contentType {
+			t.Errorf("expected %d %s for %q, got %d %s", expected.statusCode, expected.contentType, accept,
+				recorder.Code, recorder.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	api := Typed(greet)
+	if status := executeTyped(api, "/greet?times=5", `{}`, ""); status.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid request, got %d", status.StatusCode)
+	}
+	if status := executeTyped(api, "/greet", `{"name": `, ""); status.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for malformed body, got %d", status.StatusCode)
+	}
+	if status := executeTyped(api, "/greet", `{"name": "nobody"}`, ""); status.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected HttpError status code to be used, got %d", status.StatusCode)
+	}
+}
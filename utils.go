@@ -2,6 +2,8 @@ package stgin
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"github.com/AminMal/slogger/colored"
 	"path"
 	"runtime"
@@ -72,3 +74,37 @@ func printStacktrace(firstLine string) {
 func normalizePath(path string) string {
 	return multipleSlashesRegex.ReplaceAllString(path, "/")
 }
+
+// qualityValue is an entry of http headers like Accept or Accept-Encoding, with its quality factor (q).
+type qualityValue struct {
+	value   string
+	quality float64
+	index   int
+}
+
+// parseQualityList parses headers like "gzip;q=0.8, br" into their values, sorted by quality (and then their order),
+// values with a quality of zero are kept, since they explicitly reject the value.
+func parseQualityList(header string) []qualityValue {
+	var result []qualityValue
+	for i, entry := range strings.Split(header, ",") {
+		parts := strings.Split(entry, ";")
+		value := strings.ToLower(strings.TrimSpace(parts[0]))
+		if value == "" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range parts[1:] {
+			key, raw := splitBy(strings.TrimSpace(param), "=")
+			if strings.ToLower(key) == "q" {
+				if parsed, err := strconv.ParseFloat(raw, 64); err == nil && parsed >= 0 && parsed <= 1 {
+					quality = parsed
+				} else {
+					quality = 0
+				}
+			}
+		}
+		result = append(result, qualityValue{value: value, quality: quality, index: i})
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].quality > result[j].quality })
+	return result
+}
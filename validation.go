@@ -0,0 +1,272 @@
+package stgin
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/AminMal/slogger/colored"
+)
+
+// Validator is a custom validation rule, which can be used inside `validate` tags after being added using AddValidator.
+// It receives the value of the field and the parameter of the rule (i.e., "5" in `validate:"divisible=5"`),
+// and returns an error describing why the value is not valid, which is then reported as the message of the field.
+type Validator = func(value any, param string) error
+
+type validationRule = func(value reflect.Value, param string) error
+
+var emailRegex = regexp.MustCompile("^[^@\\s]+@[^@\\s]+\\.[^@\\s]+$")
+
+var builtinValidators = map[string]validationRule{
+	"min": func(value reflect.Value, param string) error {
+		return compareSize(value, param, "at least", func(size, limit float64) bool { return size >= limit })
+	},
+	"max": func(value reflect.Value, param string) error {
+		return compareSize(value, param, "at most", func(size, limit float64) bool { return size <= limit })
+	},
+	"len": func(value reflect.Value, param string) error {
+		return compareSize(value, param, "exactly", func(size, limit float64) bool { return size == limit })
+	},
+	"email": func(value reflect.Value, _ string) error {
+		if value.Kind() != reflect.String || !emailRegex.MatchString(value.String()) {
+			return errors.New("must be a valid email address")
+		}
+		return nil
+	},
+	"url": func(value reflect.Value, _ string) error {
+		if value.Kind() == reflect.String {
+			if parsed, err := url.ParseRequestURI(value.String()); err == nil && parsed.Scheme != "" && parsed.Host != "" {
+				return nil
+			}
+		}
+		return errors.New("must be a valid absolute url")
+	},
+	"oneof": func(value reflect.Value, param string) error {
+		options := strings.Fields(param)
+		actual := fmt.Sprint(value.Interface())
+		for _, option := range options {
+			if option == actual {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of [%s]", strings.Join(options, ", "))
+	},
+}
+
+var customValidators struct {
+	sync.RWMutex
+	rules map[string]validationRule
+}
+
+// AddValidator registers a custom validation rule by the given name, which then can be used inside `validate` tags.
+// It fails if the name is empty, contains tag separators, or tries to override the default rules
+// (`required`, `omitempty`, `min`, `max`, `len`, `email`, `url`, `oneof`). It's safe to be called concurrently with validations.
+func AddValidator(name string, validator Validator) error {
+	if name == "required" || name == "omitempty" || builtinValidators[name] != nil {
+		return errors.New("cannot modify basic validation rules")
+	}
+	if name == "" || strings.ContainsAny(name, ",= ") {
+		return fmt.Errorf("'%s' is not a valid validation rule name", name)
+	}
+	if validator == nil {
+		return errors.New("cannot use nil as a validator")
+	}
+	customValidators.Lock()
+	defer customValidators.Unlock()
+	if customValidators.rules == nil {
+		customValidators.rules = map[string]validationRule{}
+	}
+	customValidators.rules[name] = func(value reflect.Value, param string) error {
+		return validator(value.Interface(), param)
+	}
+	return nil
+}
+
+// compareSize compares the numeric value of numbers, or the length of strings, slices and maps against param.
+func compareSize(value reflect.Value, param string, description string, accepts func(size, limit float64) bool) error {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return fmt.Errorf("has an invalid validation rule parameter '%s'", param)
+	}
+	var size float64
+	var unit string
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size = float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		size = value.Float()
+	case reflect.String:
+		size, unit = float64(utf8.RuneCountInString(value.String())), " characters long"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		size, unit = float64(value.Len()), " items long"
+	default:
+		return fmt.Errorf("cannot be compared using size rules (type %s)", value.Type().String())
+	}
+	if !accepts(size, limit) {
+		return fmt.Errorf("must be %s %s%s", description, param, unit)
+	}
+	return nil
+}
+
+func isEmptyValue(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String, reflect.Array:
+		return value.Len() == 0
+	default:
+		return value.IsZero()
+	}
+}
+
+// validationFieldName returns the name of the field inside the first tag of nameTags which is defined on the field,
+// so that the reported paths match the names which clients have sent.
+func validationFieldName(field reflect.StructField, nameTags []string) string {
+	for _, tagKey := range nameTags {
+		if tag, found := field.Tag.Lookup(tagKey); found {
+			name := strings.Split(tag, ",")[0]
+			if name != "" && name != "-" {
+				return name
+			}
+		}
+	}
+	return field.Name
+}
+
+func joinFieldPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// unknownRuleWarnings holds the unknown rules which automatic validations have already warned about.
+var unknownRuleWarnings sync.Map
+
+// applyValidationRules applies the rules to the value. Unknown rules are reported as failures if strict, otherwise
+// they're ignored (with a warning logged once per rule), since the structs might use the rules of other validators.
+func applyValidationRules(value reflect.Value, path string, rules string, strict bool, errs *[]FieldError) {
+	var required, omitEmpty bool
+	var others [][2]string
+	for _, rule := range strings.Split(rules, ",") {
+		name, param := splitBy(strings.TrimSpace(rule), "=")
+		switch name {
+		case "":
+		case "required":
+			required = true
+		case "omitempty":
+			omitEmpty = true
+		default:
+			others = append(others, [2]string{name, param})
+		}
+	}
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			if required {
+				*errs = append(*errs, FieldError{Field: path, Message: "is required"})
+			}
+			return
+		}
+		value = value.Elem()
+	}
+	empty := isEmptyValue(value)
+	if required && empty {
+		*errs = append(*errs, FieldError{Field: path, Message: "is required"})
+		return
+	}
+	if omitEmpty && empty {
+		return
+	}
+	for _, rule := range others {
+		validator := builtinValidators[rule[0]]
+		if validator == nil {
+			customValidators.RLock()
+			validator = customValidators.rules[rule[0]]
+			customValidators.RUnlock()
+		}
+		if validator == nil {
+			if strict {
+				*errs = append(*errs, FieldError{Field: path, Message: fmt.Sprintf("has an unknown validation rule '%s'", rule[0])})
+			} else if _, warned := unknownRuleWarnings.LoadOrStore(rule[0], true); !warned {
+				_ = stginLogger.Colored(colored.YELLOW).InfoF("ignoring unknown validation rule '%s' of %s", rule[0], path)
+			}
+			continue
+		}
+		if err := validator(value, rule[1]); err != nil {
+			*errs = append(*errs, FieldError{Field: path, Message: err.Error()})
+		}
+	}
+}
+
+func validateValue(value reflect.Value, path string, nameTags []string, strict bool, errs *[]FieldError) {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Struct:
+		if value.Type() == timeType {
+			return
+		}
+		tpe := value.Type()
+		for i := 0; i < tpe.NumField(); i++ {
+			field := tpe.Field(i)
+			if !field.IsExported() && !(field.Anonymous && field.Type.Kind() == reflect.Struct) {
+				continue
+			}
+			rules := field.Tag.Get("validate")
+			if rules == "-" {
+				continue
+			}
+			fieldPath := joinFieldPath(path, validationFieldName(field, nameTags))
+			if field.Anonymous {
+				fieldPath = path
+			}
+			if rules != "" {
+				applyValidationRules(value.Field(i), fieldPath, rules, strict, errs)
+			}
+			validateValue(value.Field(i), fieldPath, nameTags, strict, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		switch value.Type().Elem().Kind() {
+		case reflect.Struct, reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Array:
+		default:
+			return
+		}
+		for i := 0; i < value.Len(); i++ {
+			validateValue(value.Index(i), fmt.Sprintf("%s[%d]", path, i), nameTags, strict, errs)
+		}
+	}
+}
+
+// validateWithNames is the automatic validation of bound objects, which ignores unknown rules.
+func validateWithNames(a any, nameTags ...string) error {
+	return validateObject(a, false, nameTags)
+}
+
+func validateObject(a any, strict bool, nameTags []string) error {
+	var fieldErrors []FieldError
+	validateValue(reflect.ValueOf(a), "", nameTags, strict, &fieldErrors)
+	if len(fieldErrors) != 0 {
+		return ValidationError{Errors: fieldErrors}
+	}
+	return nil
+}
+
+// Validate checks the given object against the rules defined in its `validate` tags, like
+// `validate:"required,min=1,max=64"`. Nested structs and slices of structs are validated as well.
+// This is done automatically after binding request values using Bind, QueryToObj or the body parsing functions,
+// but can also be used manually. If any field is not valid, a ValidationError describing all of them is returned.
+// Unlike the automatic validation, which ignores the rules it doesn't know (i.e., rules of other validation libraries),
+// Validate reports them as failures.
+func Validate(a any) error {
+	return validateObject(a, true, []string{"json"})
+}
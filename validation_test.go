@@ -0,0 +1,134 @@
+package stgin
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type signup struct {
+	Username  string    `json:"username" validate:"required,min=3,max=12"`
+	Email     string    `json:"email" validate:"required,email"`
+	Plan      string    `json:"plan" validate:"oneof=free pro"`
+	Website   string    `json:"website" validate:"omitempty,url"`
+	Age       *int      `json:"age" validate:"omitempty,min=18"`
+	Tags      []string  `json:"tags" validate:"max=2"`
+	Addresses []address `json:"addresses"`
+}
+
+func TestValidate(t *testing.T) {
+	valid := signup{Username: "john", Email: "john@doe.com", Plan: "pro"}
+	if err := Validate(&valid); err != nil {
+		t.Fatalf("valid object got rejected: %s", err.Error())
+	}
+
+	age := 12
+	invalid := signup{
+		Username:  "jo",
+		Email:     "john",
+		Plan:      "gold",
+		Website:   "not a url",
+		Age:       &age,
+		Tags:      []string{"a", "b", "c"},
+		Addresses: []address{{City: "Paris"}, {}},
+	}
+	err := Validate(&invalid)
+	validationErr, isValidationErr := err.(ValidationError)
+	if !isValidationErr {
+		t.Fatalf("expected validation error, got: %v", err)
+	}
+	var fields []string
+	for _, fieldErr := range validationErr.Errors {
+		fields = append(fields, fieldErr.Field)
+	}
+	expected := []string{"username", "email", "plan", "website", "age", "tags", "addresses[1].city"}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Fatalf("validation did not report the expected fields, got: %v", fields)
+	}
+}
+
+func TestAddValidator(t *testing.T) {
+	if err := AddValidator("min", func(any, string) error { return nil }); err == nil {
+		t.Fatal("overriding basic validation rules got accepted")
+	}
+	err := AddValidator("even", func(value any, _ string) error {
+		if value.(int)%2 != 0 {
+			return errors.New("must be even")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("could not add custom validator: %s", err.Error())
+	}
+	type evenHolder struct {
+		Number int `json:"number" validate:"even"`
+	}
+	body := bodyFromBytes([]byte(`{"number": 3}`))
+	var holder evenHolder
+	err = body.SafeJSONInto(&holder)
+	expected := ValidationError{Errors: []FieldError{{Field: "number", Message: "must be even"}}}
+	if !reflect.DeepEqual(err, expected) {
+		t.Fatalf("custom validator was not applied after parsing json, got: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			_ = AddValidator("concurrent"+strconv.Itoa(i), func(any, string) error { return nil })
+		}(i)
+		go func() {
+			defer wg.Done()
+			var holder evenHolder
+			_ = bodyFromBytes([]byte(`{"number": 2}`)).SafeJSONInto(&holder)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestUnknownValidationRules(t *testing.T) {
+	type legacyRequest struct {
+		Page int    `json:"page" validate:"gte=1"`
+		Name string `json:"name" validate:"required,alphanum"`
+	}
+	var request legacyRequest
+	if err := bodyFromBytes([]byte(`{"page": 2, "name": "john"}`)).SafeJSONInto(&request); err != nil {
+		t.Fatalf("unknown rules failed the automatic validation: %v", err)
+	}
+	err := bodyFromBytes([]byte(`{"page": 2}`)).SafeJSONInto(&legacyRequest{})
+	expected := ValidationError{Errors: []FieldError{{Field: "name", Message: "is required"}}}
+	if !reflect.DeepEqual(err, expected) {
+		t.Fatalf("known rules were not applied next to the unknown ones, got: %v", err)
+	}
+	err = Validate(legacyRequest{Page: 2, Name: "john"})
+	expected = ValidationError{Errors: []FieldError{
+		{Field: "page", Message: "has an unknown validation rule 'gte'"},
+		{Field: "name", Message: "has an unknown validation rule 'alphanum'"},
+	}}
+	if !reflect.DeepEqual(err, expected) {
+		t.Fatalf("expected Validate to report the unknown rules, got: %v", err)
+	}
+}
+
+func TestValidationErrorResponse(t *testing.T) {
+	request := RequestContext{Url: "/signup", Method: "POST"}
+	status := errorAction(request, ValidationError{Errors: []FieldError{{Field: "email", Message: "is required"}}})
+	if status.StatusCode != 400 {
+		t.Fatalf("expected 400 for validation errors, got %d", status.StatusCode)
+	}
+	bytes, _ := status.Entity.Bytes()
+	var problem Problem
+	_ = json.Unmarshal(bytes, &problem)
+	errors, _ := problem.Extensions["errors"].([]any)
+	if len(errors) != 1 || errors[0].(map[string]any)["field"] != "email" || problem.Status != 400 {
+		t.Fatalf("validation errors were not reported in the response, got: %s", string(bytes))
+	}
+}
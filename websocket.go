@@ -0,0 +1,728 @@
+package stgin
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Message types of websocket messages.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+)
+
+// Close codes of websocket connections, as defined in RFC 6455.
+const (
+	CloseNormalClosure       = 1000
+	CloseGoingAway           = 1001
+	CloseProtocolError       = 1002
+	CloseUnsupportedData     = 1003
+	CloseNoStatusReceived    = 1005
+	CloseAbnormalClosure     = 1006
+	CloseInvalidPayload      = 1007
+	ClosePolicyViolation     = 1008
+	CloseMessageTooBig       = 1009
+	CloseInternalServerError = 1011
+)
+
+const (
+	continuationFrame = 0
+	closeFrame        = 8
+	pingFrame         = 9
+	pongFrame         = 10
+
+	maxControlPayload = 125
+	// defaultReadLimit is the maximum size of the messages read from connections, if not configured.
+	defaultReadLimit = 1 << 20
+	// maxPreallocatedPayload is the most memory allocated for a payload before it's received, bigger payloads
+	// (which are only allowed without read limits) grow as they're read, so that frame headers cannot force allocations.
+	maxPreallocatedPayload = 64 << 10
+	// closeGracePeriod is how long closing connections wait for the peer to acknowledge the close frame.
+	closeGracePeriod = time.Second
+
+	webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+)
+
+// deflateTail is removed from compressed messages, and appended back (with an empty final block) to decompress them (RFC 7692).
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+// ErrWebSocketClosed is returned when writing into websocket connections which are already closed.
+var ErrWebSocketClosed = errors.New("websocket connection is closed")
+
+// WebSocketConfig holds the specifications of websocket routes.
+type WebSocketConfig struct {
+	// ReadLimit is the maximum size of the messages (after decompression) read from the connections in bytes,
+	// bigger messages close the connection with CloseMessageTooBig. Defaults to 1MB.
+	ReadLimit int64
+	// EnableCompression negotiates per-message compression (permessage-deflate) with the clients which support it.
+	EnableCompression bool
+	// Subprotocols are the supported subprotocols in the order of preference, the first one requested by the client is chosen.
+	Subprotocols []string
+	// CheckOrigin decides whether the connection is accepted for the request's Origin header.
+	// By default, the requests with an Origin header must come from the same host.
+	CheckOrigin func(request RequestContext) bool
+	// PingInterval is the interval of the pings sent to the clients, if it's not zero.
+	// Connections which do not receive anything (including pongs) for twice the interval are closed.
+	PingInterval time.Duration
+}
+
+// WebSocket defines a route which upgrades the requests matching the pattern into websocket connections.
+// Just like other routes, the controller prefix, path parameters, request listeners and interrupts apply to the handshake,
+// and the handler is called with the request (i.e., to access path parameters) once the connection is established.
+// The connection is closed when the handler returns.
+func WebSocket(pattern string, handler func(request RequestContext, conn *WebSocketConn)) Route {
+	return WebSocketWithConfig(pattern, handler, WebSocketConfig{})
+}
+
+// WebSocketWithConfig defines a websocket route just like WebSocket, with the given config.
+func WebSocketWithConfig(pattern string, handler func(request RequestContext, conn *WebSocketConn), config WebSocketConfig) Route {
+	if handler == nil {
+		printStacktrace("")
+		panic("cannot use nil as a websocket handler")
+	}
+	return mkRoute(pattern, webSocketAPI(handler, config), http.MethodGet)
+}
+
+func headerContainsToken(headers http.Header, key string, token string) bool {
+	for _, value := range headers.Values(key) {
+		for _, element := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(element), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sameOrigin(request RequestContext) bool {
+	origin := request.Headers.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(parsed.Host, request.Host)
+}
+
+// webSocketAccept computes the Sec-WebSocket-Accept header of the handshake response.
+func webSocketAccept(key string) string {
+	hash := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// negotiateCompression accepts the first permessage-deflate offer which works without context takeover
+// and with the full window size, which is what compress/flate supports.
+func negotiateCompression(headers http.Header) bool {
+	for _, value := range headers.Values("Sec-WebSocket-Extensions") {
+		for _, offer := range strings.Split(value, ",") {
+			params := strings.Split(offer, ";")
+			if strings.TrimSpace(params[0]) != "permessage-deflate" {
+				continue
+			}
+			acceptable := true
+			for _, param := range params[1:] {
+				name, _ := splitBy(strings.TrimSpace(param), "=")
+				if name == "server_max_window_bits" {
+					acceptable = false
+				}
+			}
+			if acceptable {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func webSocketHandshakeFailure(request RequestContext, statusCode int, message string) Status {
+	return failureResponse(request, statusCode, message)
+}
+
+// webSocketAPI validates the handshake, the connection itself is upgraded once the response is being written.
+func webSocketAPI(handler func(request RequestContext, conn *WebSocketConn), config WebSocketConfig) API {
+	return func(request RequestContext) Status {
+		if !headerContainsToken(request.Headers, "Connection", "upgrade") || !headerContainsToken(request.Headers, "Upgrade", "websocket") {
+			return webSocketHandshakeFailure(request, http.StatusBadRequest, "not a websocket handshake")
+		}
+		if request.Headers.Get("Sec-WebSocket-Version") != "13" {
+			return webSocketHandshakeFailure(request, http.StatusUpgradeRequired, "unsupported websocket version").
+				WithHeader("Sec-WebSocket-Version", "13")
+		}
+		key := request.Headers.Get("Sec-WebSocket-Key")
+		if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+			return webSocketHandshakeFailure(request, http.StatusBadRequest, "invalid websocket key")
+		}
+		checkOrigin := config.CheckOrigin
+		if checkOrigin == nil {
+			checkOrigin = sameOrigin
+		}
+		if !checkOrigin(request) {
+			return webSocketHandshakeFailure(request, http.StatusForbidden, "origin not allowed")
+		}
+		headers := http.Header{}
+		headers.Set("Upgrade", "websocket")
+		headers.Set("Connection", "Upgrade")
+		headers.Set("Sec-WebSocket-Accept", webSocketAccept(key))
+		entity := webSocketEntity{request: request, handler: handler, config: config}
+		if subprotocol := chooseSubprotocol(request.Headers, config.Subprotocols); subprotocol != "" {
+			headers.Set("Sec-WebSocket-Protocol", subprotocol)
+			entity.subprotocol = subprotocol
+		}
+		if config.EnableCompression && negotiateCompression(request.Headers) {
+			headers.Set("Sec-WebSocket-Extensions", "permessage-deflate; server_no_context_takeover; client_no_context_takeover")
+			entity.compression = true
+		}
+		return Status{StatusCode: http.StatusSwitchingProtocols, Entity: entity, Headers: headers}
+	}
+}
+
+func chooseSubprotocol(headers http.Header, supported []string) string {
+	for _, subprotocol := range supported {
+		if headerContainsToken(headers, "Sec-WebSocket-Protocol", subprotocol) {
+			return subprotocol
+		}
+	}
+	return ""
+}
+
+type webSocketEntity struct {
+	request     RequestContext
+	handler     func(request RequestContext, conn *WebSocketConn)
+	config      WebSocketConfig
+	subprotocol string
+	compression bool
+}
+
+func (we webSocketEntity) ContentType() string { return "" }
+
+func (we webSocketEntity) Bytes() ([]byte, error) {
+	return nil, errors.New("websocket connections can only be written into responses")
+}
+
+// serve hijacks the connection, writes the handshake response (with the headers of the status),
+// and runs the handler on the upgraded connection.
+func (we webSocketEntity) serve(writer http.ResponseWriter, _ *http.Request, statusCode int) error {
+	hijacker, isHijacker := writer.(http.Hijacker)
+	if !isHijacker {
+		return errors.New("websockets are not supported by the response writer")
+	}
+	netConn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return err
+	}
+	// deadlines of the http server must not apply to the websocket connection
+	_ = netConn.SetDeadline(time.Time{})
+	var handshake bytes.Buffer
+	_, _ = fmt.Fprintf(&handshake, "HTTP/1.1 %d %s\r\n", statusCode, http.StatusText(statusCode))
+	headers := writer.Header().Clone()
+	headers.Del(contentTypeKey)
+	headers.Del("Content-Length")
+	_ = headers.Write(&handshake)
+	handshake.WriteString("\r\n")
+	if _, err = netConn.Write(handshake.Bytes()); err != nil {
+		_ = netConn.Close()
+		return nil
+	}
+	conn := newWebSocketConn(netConn, buffered.Reader, false, we.compression)
+	conn.subprotocol = we.subprotocol
+	if we.config.ReadLimit != 0 {
+		conn.readLimit = we.config.ReadLimit
+	}
+	if we.config.PingInterval > 0 {
+		conn.idleTimeout = 2 * we.config.PingInterval
+		_ = netConn.SetReadDeadline(time.Now().Add(conn.idleTimeout))
+		go conn.keepAlive(we.config.PingInterval)
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			printStacktrace(fmt.Sprintf("recovering websocket handler error: %v", err))
+			_ = conn.Close(CloseInternalServerError, "internal server error")
+			return
+		}
+		_ = conn.Close(CloseNormalClosure, "")
+	}()
+	we.handler(we.request, conn)
+	return nil
+}
+
+// WebSocketConn is a message-oriented websocket connection.
+// Only one goroutine may read messages at a time, while writing (and closing) is safe to be done concurrently.
+// Pings are answered automatically while messages are being read.
+type WebSocketConn struct {
+	netConn     net.Conn
+	reader      *bufio.Reader
+	isClient    bool
+	compression bool
+	subprotocol string
+	readLimit   int64
+	idleTimeout time.Duration
+
+	readMutex        sync.Mutex
+	writeMutex       sync.Mutex
+	writeCompression bool
+	closeSent        bool
+	closed           chan struct{}
+	closeOnce        sync.Once
+
+	pongHandler func(data []byte)
+}
+
+func newWebSocketConn(netConn net.Conn, reader *bufio.Reader, isClient bool, compression bool) *WebSocketConn {
+	if reader == nil {
+		reader = bufio.NewReader(netConn)
+	}
+	return &WebSocketConn{
+		netConn:          netConn,
+		reader:           reader,
+		isClient:         isClient,
+		compression:      compression,
+		writeCompression: compression,
+		readLimit:        defaultReadLimit,
+		closed:           make(chan struct{}),
+	}
+}
+
+// Subprotocol returns the subprotocol negotiated during the handshake, if any.
+func (conn *WebSocketConn) Subprotocol() string { return conn.subprotocol }
+
+// RemoteAddr returns the network address of the peer.
+func (conn *WebSocketConn) RemoteAddr() net.Addr { return conn.netConn.RemoteAddr() }
+
+// SetReadLimit sets the maximum size of the messages read from the connection, bigger messages close the connection
+// with CloseMessageTooBig. Non-positive values remove the limit.
+func (conn *WebSocketConn) SetReadLimit(limit int64) { conn.readLimit = limit }
+
+// SetReadDeadline sets the deadline of reading messages from the connection.
+func (conn *WebSocketConn) SetReadDeadline(deadline time.Time) error {
+	return conn.netConn.SetReadDeadline(deadline)
+}
+
+// SetWriteDeadline sets the deadline of writing messages into the connection.
+func (conn *WebSocketConn) SetWriteDeadline(deadline time.Time) error {
+	return conn.netConn.SetWriteDeadline(deadline)
+}
+
+// EnableWriteCompression turns compressing the written messages on or off, if compression is negotiated with the peer.
+func (conn *WebSocketConn) EnableWriteCompression(enable bool) {
+	conn.writeMutex.Lock()
+	conn.writeCompression = enable && conn.compression
+	conn.writeMutex.Unlock()
+}
+
+// SetPongHandler sets the function which is called with the pongs received while reading messages.
+func (conn *WebSocketConn) SetPongHandler(handler func(data []byte)) { conn.pongHandler = handler }
+
+// Done is closed when the connection is closed.
+func (conn *WebSocketConn) Done() <-chan struct{} { return conn.closed }
+
+// frameHeader builds the header of a frame, masking is done by the caller.
+func frameHeader(opcode byte, compressed bool, length int, mask []byte) []byte {
+	header := make([]byte, 0, 14)
+	first := 0x80 | opcode
+	if compressed {
+		first |= 0x40
+	}
+	header = append(header, first)
+	var maskBit byte
+	if mask != nil {
+		maskBit = 0x80
+	}
+	switch {
+	case length <= 125:
+		header = append(header, maskBit|byte(length))
+	case length <= 0xffff:
+		header = append(header, maskBit|126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header = append(header, maskBit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+	return append(header, mask...)
+}
+
+func maskBytes(mask []byte, payload []byte) {
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+}
+
+func (conn *WebSocketConn) writeFrame(opcode byte, payload []byte, compressed bool) error {
+	var mask []byte
+	if conn.isClient {
+		mask = make([]byte, 4)
+		if _, err := rand.Read(mask); err != nil {
+			return err
+		}
+		payload = append([]byte(nil), payload...)
+		maskBytes(mask, payload)
+	}
+	frame := append(frameHeader(opcode, compressed, len(payload), mask), payload...)
+	_, err := conn.netConn.Write(frame)
+	return err
+}
+
+func compressMessage(data []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+	writer, err := flate.NewWriter(&compressed, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err = writer.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(compressed.Bytes(), deflateTail[:4]), nil
+}
+
+// WriteMessage writes a text or binary message into the connection.
+func (conn *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("invalid websocket message type %d", messageType)
+	}
+	conn.writeMutex.Lock()
+	defer conn.writeMutex.Unlock()
+	if conn.closeSent {
+		return ErrWebSocketClosed
+	}
+	if conn.writeCompression {
+		compressed, err := compressMessage(data)
+		if err != nil {
+			return err
+		}
+		return conn.writeFrame(byte(messageType), compressed, true)
+	}
+	return conn.writeFrame(byte(messageType), data, false)
+}
+
+// WriteText writes a text message into the connection.
+func (conn *WebSocketConn) WriteText(text string) error {
+	return conn.WriteMessage(TextMessage, []byte(text))
+}
+
+// WriteJSON writes the given object as a JSON text message into the connection.
+func (conn *WebSocketConn) WriteJSON(a any) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(TextMessage, data)
+}
+
+func (conn *WebSocketConn) writeControl(opcode byte, payload []byte) error {
+	if len(payload) > maxControlPayload {
+		return fmt.Errorf("websocket control frames cannot be longer than %d bytes", maxControlPayload)
+	}
+	conn.writeMutex.Lock()
+	defer conn.writeMutex.Unlock()
+	if conn.closeSent {
+		return ErrWebSocketClosed
+	}
+	if opcode == closeFrame {
+		conn.closeSent = true
+	}
+	return conn.writeFrame(opcode, payload, false)
+}
+
+// Ping sends a ping to the peer, which is answered with a pong (see SetPongHandler).
+func (conn *WebSocketConn) Ping(data []byte) error {
+	return conn.writeControl(pingFrame, data)
+}
+
+func (conn *WebSocketConn) keepAlive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if conn.Ping(nil) != nil {
+				return
+			}
+		case <-conn.closed:
+			return
+		}
+	}
+}
+
+func closePayload(code int, reason string) []byte {
+	if code == CloseNoStatusReceived {
+		return nil
+	}
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	if len(reason) > maxControlPayload-2 {
+		reason = reason[:maxControlPayload-2]
+	}
+	return append(payload, reason...)
+}
+
+// Close sends a close frame with the given code and reason (which is truncated to 123 bytes), waits shortly for the
+// peer to acknowledge it, and closes the underlying connection. Closing an already closed connection does nothing.
+func (conn *WebSocketConn) Close(code int, reason string) error {
+	err := conn.writeControl(closeFrame, closePayload(code, reason))
+	if errors.Is(err, ErrWebSocketClosed) {
+		conn.closeNetConn()
+		return nil
+	}
+	if err == nil {
+		conn.awaitClose()
+	}
+	conn.closeNetConn()
+	return err
+}
+
+// awaitClose reads (and drops) the frames until the peer's close frame.
+// If some goroutine is already reading messages, it receives the close frame instead.
+func (conn *WebSocketConn) awaitClose() {
+	if !conn.readMutex.TryLock() {
+		select {
+		case <-conn.closed:
+		case <-time.After(closeGracePeriod):
+		}
+		return
+	}
+	defer conn.readMutex.Unlock()
+	_ = conn.netConn.SetReadDeadline(time.Now().Add(closeGracePeriod))
+	for {
+		opcode, _, _, err := conn.readFrame()
+		if err != nil || opcode == closeFrame {
+			return
+		}
+	}
+}
+
+func (conn *WebSocketConn) closeNetConn() {
+	conn.closeOnce.Do(func() {
+		_ = conn.netConn.Close()
+		close(conn.closed)
+	})
+}
+
+// fail closes the connection because of the peer's misbehavior.
+func (conn *WebSocketConn) fail(code int, reason string) error {
+	_ = conn.writeControl(closeFrame, closePayload(code, reason))
+	conn.closeNetConn()
+	return WebSocketCloseError{Code: code, Reason: reason}
+}
+
+// readFrame reads the next frame, control frames are validated here.
+func (conn *WebSocketConn) readFrame() (opcode byte, fin bool, payload []byte, err error) {
+	header := make([]byte, 2)
+	if _, err = io.ReadFull(conn.reader, header); err != nil {
+		return
+	}
+	if conn.idleTimeout > 0 {
+		_ = conn.netConn.SetReadDeadline(time.Now().Add(conn.idleTimeout))
+	}
+	fin = header[0]&0x80 != 0
+	rsv := header[0] & 0x70
+	opcode = header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7f)
+	if rsv != 0 && !(rsv == 0x40 && conn.compression && (opcode == TextMessage || opcode == BinaryMessage)) {
+		err = conn.fail(CloseProtocolError, "unexpected reserved bits")
+		return
+	}
+	if masked == conn.isClient {
+		err = conn.fail(CloseProtocolError, "invalid frame masking")
+		return
+	}
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		if _, err = io.ReadFull(conn.reader, extended); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		if _, err = io.ReadFull(conn.reader, extended); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(extended)
+		if length>>63 != 0 {
+			err = conn.fail(CloseProtocolError, "invalid payload length")
+			return
+		}
+	}
+	if opcode >= closeFrame && (length > maxControlPayload || !fin) {
+		err = conn.fail(CloseProtocolError, "invalid control frame")
+		return
+	}
+	if conn.readLimit > 0 && length > uint64(conn.payloadLimit(conn.compression)) {
+		err = conn.fail(CloseMessageTooBig, "message too big")
+		return
+	}
+	var mask []byte
+	if masked {
+		mask = make([]byte, 4)
+		if _, err = io.ReadFull(conn.reader, mask); err != nil {
+			return
+		}
+	}
+	if payload, err = conn.readPayload(length); err != nil {
+		return
+	}
+	if masked {
+		maskBytes(mask, payload)
+	}
+	if rsv == 0x40 {
+		// only the first frame of compressed messages has the compression bit
+		opcode |= 0x40
+	}
+	return
+}
+
+func (conn *WebSocketConn) readPayload(length uint64) ([]byte, error) {
+	if length <= maxPreallocatedPayload {
+		payload := make([]byte, length)
+		_, err := io.ReadFull(conn.reader, payload)
+		return payload, err
+	}
+	buffer := bytes.NewBuffer(make([]byte, 0, maxPreallocatedPayload))
+	read, err := buffer.ReadFrom(io.LimitReader(conn.reader, int64(length)))
+	if err == nil && uint64(read) < length {
+		err = io.ErrUnexpectedEOF
+	}
+	return buffer.Bytes(), err
+}
+
+// payloadLimit is the maximum size of the received payloads. Deflate makes incompressible messages slightly bigger
+// (5 bytes for each 64KB block), so compressed payloads are allowed to exceed the read limit, which applies after decompression.
+func (conn *WebSocketConn) payloadLimit(compressed bool) int64 {
+	if compressed {
+		return conn.readLimit + conn.readLimit/8192 + 1024
+	}
+	return conn.readLimit
+}
+
+func (conn *WebSocketConn) decompress(payload []byte) ([]byte, error) {
+	reader := flate.NewReader(io.MultiReader(bytes.NewReader(payload), bytes.NewReader(deflateTail)))
+	defer reader.Close()
+	if conn.readLimit <= 0 {
+		return io.ReadAll(reader)
+	}
+	decompressed, err := io.ReadAll(io.LimitReader(reader, conn.readLimit+1))
+	if err == nil && int64(len(decompressed)) > conn.readLimit {
+		return nil, conn.fail(CloseMessageTooBig, "message too big")
+	}
+	return decompressed, err
+}
+
+// ReadMessage reads the next text or binary message from the connection, answering pings and handing pongs to the
+// pong handler meanwhile. If the peer closes the connection, a WebSocketCloseError holding its close code is returned.
+func (conn *WebSocketConn) ReadMessage() (messageType int, data []byte, err error) {
+	conn.readMutex.Lock()
+	defer conn.readMutex.Unlock()
+	var message []byte
+	var compressed bool
+	for {
+		opcode, fin, payload, err := conn.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		frameCompressed := opcode&0x40 != 0
+		opcode &= 0x0f
+		switch opcode {
+		case pingFrame:
+			if err = conn.writeControl(pongFrame, payload); err != nil && !errors.Is(err, ErrWebSocketClosed) {
+				return 0, nil, err
+			}
+			continue
+		case pongFrame:
+			if conn.pongHandler != nil {
+				conn.pongHandler(payload)
+			}
+			continue
+		case closeFrame:
+			return 0, nil, conn.receiveClose(payload)
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, conn.fail(CloseProtocolError, "expected a continuation frame")
+			}
+			messageType, compressed = int(opcode), frameCompressed
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, conn.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		default:
+			return 0, nil, conn.fail(CloseProtocolError, "unknown frame type")
+		}
+		message = append(message, payload...)
+		if conn.readLimit > 0 && int64(len(message)) > conn.payloadLimit(compressed) {
+			return 0, nil, conn.fail(CloseMessageTooBig, "message too big")
+		}
+		if !fin {
+			continue
+		}
+		if compressed {
+			if message, err = conn.decompress(message); err != nil {
+				var closeErr WebSocketCloseError
+				if errors.As(err, &closeErr) {
+					return 0, nil, err
+				}
+				return 0, nil, conn.fail(CloseInvalidPayload, "invalid compressed message")
+			}
+		}
+		if messageType == TextMessage && !utf8.Valid(message) {
+			return 0, nil, conn.fail(CloseInvalidPayload, "invalid utf-8 text message")
+		}
+		if message == nil {
+			message = []byte{}
+		}
+		return messageType, message, nil
+	}
+}
+
+// validCloseCode reports whether the code can be sent in close frames (RFC 6455, section 7.4).
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	default:
+		return code >= 3000 && code <= 4999
+	}
+}
+
+// receiveClose acknowledges the peer's close frame, and closes the connection.
+func (conn *WebSocketConn) receiveClose(payload []byte) error {
+	closeErr := WebSocketCloseError{Code: CloseNoStatusReceived}
+	if len(payload) == 1 {
+		return conn.fail(CloseProtocolError, "invalid close frame")
+	}
+	if len(payload) >= 2 {
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Reason = string(payload[2:])
+		if !validCloseCode(closeErr.Code) || !utf8.ValidString(closeErr.Reason) {
+			return conn.fail(CloseProtocolError, "invalid close frame")
+		}
+	}
+	_ = conn.writeControl(closeFrame, closePayload(closeErr.Code, ""))
+	conn.closeNetConn()
+	return closeErr
+}
+
+// ReadJSON reads the next message, and decodes it as JSON into the given pointer.
+func (conn *WebSocketConn) ReadJSON(a any) error {
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, a)
+}
@@ -0,0 +1,200 @@
+package stgin
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dialWebSocket performs a websocket handshake with the test server, returning the client side of the connection.
+func dialWebSocket(t *testing.T, server *httptest.Server, path string, headers http.Header) (*WebSocketConn, *http.Response) {
+	netConn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+	request, _ := http.NewRequest("GET", server.URL+path, nil)
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Sec-WebSocket-Key", key)
+	request.Header.Set("Sec-WebSocket-Version", "13")
+	for name, values := range headers {
+		request.Header[name] = values
+	}
+	if err = request.Write(netConn); err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(netConn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		_ = netConn.Close()
+		return nil, response
+	}
+	if response.Header.Get("Sec-WebSocket-Accept") != webSocketAccept(key) {
+		t.Fatalf("invalid handshake response: %v", response.Header)
+	}
+	compression := strings.HasPrefix(response.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+	conn := newWebSocketConn(netConn, reader, true, compression)
+	t.Cleanup(func() { _ = conn.Close(CloseNormalClosure, "") })
+	return conn, response
+}
+
+func webSocketServer(routes ...Route) (*httptest.Server, *Controller) {
+	controller := NewController("Sockets", "/ws")
+	controller.AddRoutes(routes...)
+	return streamServer(controller), controller
+}
+
+func TestWebSocket(t *testing.T) {
+	closeErrors := make(chan error, 1)
+	server, controller := webSocketServer(WebSocket("/rooms/$room:string", func(request RequestContext, conn *WebSocketConn) {
+		room := request.PathParams.All["room"]
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				closeErrors <- err
+				return
+			}
+			reply := fmt.Sprintf("%s@%s: %s", request.Headers.Get("X-User"), room, data)
+			if err = conn.WriteMessage(messageType, []byte(reply)); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+	controller.AddRequestListeners(func(request RequestContext) RequestContext {
+		request.Headers.Set("X-User", "john")
+		return request
+	})
+	controller.SetTimeout(20 * time.Millisecond)
+
+	conn, _ := dialWebSocket(t, server, "/ws/rooms/general", nil)
+	var pongs []string
+	conn.SetPongHandler(func(data []byte) { pongs = append(pongs, string(data)) })
+	if err := conn.Ping([]byte("are you there?")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond) // outlives the timeout
+	_ = conn.WriteText("hello")
+	messageType, data, err := conn.ReadMessage()
+	if err != nil || messageType != TextMessage || string(data) != "john@general: hello" {
+		t.Fatalf("unexpected message: %d %s %v", messageType, string(data), err)
+	}
+	if len(pongs) != 1 || pongs[0] != "are you there?" {
+		t.Fatalf("ping was not answered, got: %v", pongs)
+	}
+	_ = conn.WriteMessage(BinaryMessage, []byte{1, 2})
+	if messageType, _, _ = conn.ReadMessage(); messageType != BinaryMessage {
+		t.Fatalf("expected binary message, got %d", messageType)
+	}
+
+	if err = conn.Close(4000, "bye"); err != nil {
+		t.Fatal(err)
+	}
+	var closeErr WebSocketCloseError
+	if err = <-closeErrors; !errors.As(err, &closeErr) || closeErr.Code != 4000 || closeErr.Reason != "bye" {
+		t.Fatalf("close code was not received by the server, got: %v", err)
+	}
+}
+
+func TestWebSocketReadLimitAndCompression(t *testing.T) {
+	server, _ := webSocketServer(WebSocketWithConfig("/echo", func(_ RequestContext, conn *WebSocketConn) {
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			_ = conn.WriteMessage(messageType, data)
+		}
+	}, WebSocketConfig{ReadLimit: 64, EnableCompression: true}))
+	defer server.Close()
+
+	conn, response := dialWebSocket(t, server, "/ws/echo", http.Header{"Sec-Websocket-Extensions": {"permessage-deflate; client_max_window_bits"}})
+	if !conn.compression {
+		t.Fatalf("compression was not negotiated, got: %v", response.Header)
+	}
+	// compresses well below the limit, but exceeds it once decompressed
+	for _, message := range []string{strings.Repeat("a", 60), strings.Repeat("b", 100)} {
+		_ = conn.WriteText(message)
+	}
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != strings.Repeat("a", 60) {
+		t.Fatalf("compressed message was not echoed, got: %s %v", string(data), err)
+	}
+	_, _, err := conn.ReadMessage()
+	var closeErr WebSocketCloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseMessageTooBig {
+		t.Fatalf("expected the connection to be closed for the big message, got: %v", err)
+	}
+}
+
+func TestWebSocketHandshakeFailures(t *testing.T) {
+	server, _ := webSocketServer(WebSocket("/echo", func(RequestContext, *WebSocketConn) {}))
+	defer server.Close()
+
+	response, err := http.Get(server.URL + "/ws/echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for non-websocket requests, got %d", response.StatusCode)
+	}
+	if _, response = dialWebSocket(t, server, "/ws/echo", http.Header{"Origin": {"https://evil.example"}}); response.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for cross origin requests, got %d", response.StatusCode)
+	}
+	if _, response = dialWebSocket(t, server, "/ws/echo", http.Header{"Sec-Websocket-Version": {"8"}}); response.StatusCode != http.StatusUpgradeRequired {
+		t.Fatalf("expected 426 for unsupported versions, got %d", response.StatusCode)
+	}
+}
+
+func TestWebSocketFrameLengths(t *testing.T) {
+	readFrom := func(frame []byte, readLimit int64) ([]byte, error) {
+		server, client := net.Pipe()
+		defer client.Close()
+		go func() { _, _ = io.Copy(io.Discard, client) }()
+		conn := newWebSocketConn(server, bufio.NewReader(bytes.NewReader(frame)), false, false)
+		conn.SetReadLimit(readLimit)
+		_, data, err := conn.ReadMessage()
+		return data, err
+	}
+	header := func(length uint64) []byte {
+		frame := []byte{0x80 | BinaryMessage, 0x80 | 127, 0, 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint64(frame[2:], length)
+		return append(frame, 1, 2, 3, 4)
+	}
+
+	var closeErr WebSocketCloseError
+	if _, err := readFrom(header(1<<63|5), 0); !errors.As(err, &closeErr) || closeErr.Code != CloseProtocolError {
+		t.Fatalf("expected a protocol error for the most significant bit of the length, got: %v", err)
+	}
+	// without a read limit, the claimed length is not allocated before the payload arrives
+	if _, err := readFrom(append(header(1<<40), make([]byte, 10)...), 0); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected the truncated payload to be reported, got: %v", err)
+	}
+	if _, err := readFrom(header(1<<40), 1024); !errors.As(err, &closeErr) || closeErr.Code != CloseMessageTooBig {
+		t.Fatalf("expected the read limit to reject the frame, got: %v", err)
+	}
+	// bigger payloads are read incrementally
+	frame := header(100 << 10)
+	for i := 0; i < 100<<10; i++ {
+		frame = append(frame, byte('a')^frame[10+i%4])
+	}
+	if data, err := readFrom(frame, 0); err != nil || len(data) != 100<<10 || strings.Trim(string(data), "a") != "" {
+		t.Fatalf("big frame was not read, got %d bytes (%v)", len(data), err)
+	}
+}
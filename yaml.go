@@ -0,0 +1,782 @@
+package stgin
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const applicationYaml = "application/yaml"
+
+// YAMLCodec is the built-in application/yaml codec. Struct fields are named by their `yaml` tags
+// (supporting "-" and omitempty), or their names. Values are encoded in block style.
+//
+// Decoding supports a subset of YAML 1.2, which covers configuration-like documents: a single document
+// (optionally between --- and ...), block and flow collections, plain, single and double-quoted scalars
+// written in a single line, block scalars (| and >, with chomping and indentation indicators) and comments,
+// resolved using the core schema. Anything else is rejected with an error pointing at the line, including
+// anchors, aliases, tags, directives, multiple documents, complex keys (?), tabs used for indentation,
+// and plain, quoted or flow values spanning multiple lines (use block scalars instead).
+type YAMLCodec struct{}
+
+func (codec YAMLCodec) Marshal(a any) ([]byte, error) {
+	tree, err := toTree(reflect.ValueOf(a), "yaml")
+	if err != nil {
+		return nil, err
+	}
+	var builder strings.Builder
+	writeYamlNode(&builder, tree, 0)
+	return []byte(builder.String()), nil
+}
+
+func (codec YAMLCodec) Unmarshal(data []byte, a any) error {
+	target := reflect.ValueOf(a)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return fmt.Errorf("cannot decode into non-pointer %T", a)
+	}
+	tree, err := parseYaml(string(data))
+	if err != nil {
+		return err
+	}
+	return assignTree(target.Elem(), tree, "yaml", "")
+}
+
+// ------------------
+// encoding
+
+func isYamlCollection(node any) bool {
+	switch value := node.(type) {
+	case treeMap:
+		return len(value) != 0
+	case []any:
+		return len(value) != 0
+	}
+	return false
+}
+
+// writeYamlNode writes the node at the given indentation, collections are written in multiple lines.
+func writeYamlNode(builder *strings.Builder, node any, indent int) {
+	padding := strings.Repeat(" ", indent)
+	switch value := node.(type) {
+	case treeMap:
+		if len(value) == 0 {
+			builder.WriteString(padding + "{}\n")
+			return
+		}
+		for _, entry := range value {
+			builder.WriteString(padding + yamlScalar(entry.key) + ":")
+			if isYamlCollection(entry.value) {
+				builder.WriteString("\n")
+				writeYamlNode(builder, entry.value, indent+2)
+			} else {
+				builder.WriteString(" " + yamlScalar(entry.value) + "\n")
+			}
+		}
+	case []any:
+		if len(value) == 0 {
+			builder.WriteString(padding + "[]\n")
+			return
+		}
+		for _, item := range value {
+			if !isYamlCollection(item) {
+				builder.WriteString(padding + "- " + yamlScalar(item) + "\n")
+				continue
+			}
+			// the first line of the nested collection is written after the dash
+			var nested strings.Builder
+			writeYamlNode(&nested, item, indent+2)
+			builder.WriteString(padding + "- " + strings.TrimPrefix(nested.String(), padding+"  "))
+		}
+	default:
+		builder.WriteString(padding + yamlScalar(value) + "\n")
+	}
+}
+
+func yamlScalar(node any) string {
+	switch value := node.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(value)
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case uint64:
+		return strconv.FormatUint(value, 10)
+	case float64:
+		switch {
+		case math.IsInf(value, 1):
+			return ".inf"
+		case math.IsInf(value, -1):
+			return "-.inf"
+		case math.IsNaN(value):
+			return ".nan"
+		}
+		formatted := strconv.FormatFloat(value, 'g', -1, 64)
+		if _, isInt := resolveYamlScalar(formatted).(float64); !isInt {
+			// keeps floats like 2.0 as floats when decoded
+			formatted = strconv.FormatFloat(value, 'f', 1, 64)
+		}
+		return formatted
+	case string:
+		return yamlString(value)
+	case []byte:
+		return base64.StdEncoding.EncodeToString(value)
+	case treeMap:
+		return "{}"
+	case []any:
+		return "[]"
+	default:
+		return yamlString(fmt.Sprint(value))
+	}
+}
+
+// yamlString quotes the string if it would not be decoded as the same string otherwise.
+func yamlString(value string) string {
+	if value == "" || strings.TrimSpace(value) != value || strings.ContainsAny(value[:1], "-?:,[]{}#&*!|>'\"%@`") ||
+		strings.Contains(value, ": ") || strings.Contains(value, " #") || strings.HasSuffix(value, ":") ||
+		strings.IndexFunc(value, func(r rune) bool { return r < ' ' || r == 0x7f || r == utf8.RuneError }) >= 0 {
+		return strconv.Quote(value)
+	}
+	if _, isString := resolveYamlScalar(value).(string); !isString {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// ------------------
+// decoding
+
+var (
+	yamlIntRegex   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlFloatRegex = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+)
+
+// resolveYamlScalar resolves plain scalars using the core schema of YAML 1.2.
+func resolveYamlScalar(raw string) any {
+	switch raw {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return math.Inf(1)
+	case "-.inf", "-.Inf", "-.INF":
+		return math.Inf(-1)
+	case ".nan", ".NaN", ".NAN":
+		return math.NaN()
+	}
+	if yamlIntRegex.MatchString(raw) {
+		if value, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return value
+		}
+		if value, err := strconv.ParseUint(strings.TrimPrefix(raw, "+"), 10, 64); err == nil {
+			return value
+		}
+	}
+	if strings.HasPrefix(raw, "0x") || strings.HasPrefix(raw, "0o") {
+		base := 16
+		if raw[1] == 'o' {
+			base = 8
+		}
+		if value, err := strconv.ParseUint(raw[2:], base, 64); err == nil {
+			if value <= math.MaxInt64 {
+				return int64(value)
+			}
+			return value
+		}
+	}
+	if yamlFloatRegex.MatchString(raw) {
+		if value, err := strconv.ParseFloat(raw, 64); err == nil {
+			return value
+		}
+	}
+	return raw
+}
+
+type yamlLine struct {
+	number  int
+	indent  int
+	content string // without the indentation and comments
+}
+
+type yamlParser struct {
+	raw   []string
+	pos   int
+	depth int
+}
+
+func yamlErrorAt(line int, format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// stripYamlComment removes the comment of the line, which starts with a # after a white space, outside quotes.
+func stripYamlComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\', quote == '\'' && c == '\'' && i+1 < len(line) && line[i+1] == '\'':
+			// escaped characters, including the quotes of single-quoted scalars ('')
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return strings.TrimRight(line[:i], " \t")
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" \t[{,:-", line[i-1]) >= 0):
+			quote = c
+		}
+	}
+	return strings.TrimRight(line, " \t")
+}
+
+// peek finds the next line which is not empty or a comment, without consuming it.
+func (parser *yamlParser) peek() (yamlLine, bool) {
+	for parser.pos < len(parser.raw) {
+		raw := strings.TrimRight(parser.raw[parser.pos], "\r")
+		content := strings.TrimLeft(raw, " ")
+		indent := len(raw) - len(content)
+		content = stripYamlComment(content)
+		if content == "" {
+			parser.pos++
+			continue
+		}
+		return yamlLine{number: parser.pos + 1, indent: indent, content: content}, true
+	}
+	return yamlLine{}, false
+}
+
+func (parser *yamlParser) consume() {
+	parser.pos++
+}
+
+// replace replaces the current line, which is how the nodes following sequence dashes are parsed.
+func (parser *yamlParser) replace(indent int, content string) {
+	parser.raw[parser.pos] = strings.Repeat(" ", indent) + content
+}
+
+func parseYaml(document string) (any, error) {
+	parser := &yamlParser{raw: strings.Split(document, "\n")}
+	line, found := parser.peek()
+	if found && strings.HasPrefix(line.content, "%") {
+		return nil, yamlErrorAt(line.number, "directives are not supported")
+	}
+	if found && (line.content == "---" || strings.HasPrefix(line.content, "--- ")) {
+		if rest := strings.TrimSpace(strings.TrimPrefix(line.content, "---")); rest != "" {
+			parser.replace(0, rest)
+		} else {
+			parser.consume()
+		}
+		line, found = parser.peek()
+	}
+	if !found || isYamlDocumentMarker(line) {
+		return nil, nil
+	}
+	node, err := parser.parseBlock(line.indent, -1)
+	if err != nil {
+		return nil, err
+	}
+	if line, found = parser.peek(); found {
+		if line.content == "---" || strings.HasPrefix(line.content, "--- ") {
+			return nil, yamlErrorAt(line.number, "multiple documents are not supported")
+		}
+		if line.content != "..." {
+			return nil, yamlErrorAt(line.number, "unexpected content")
+		}
+	}
+	return node, nil
+}
+
+// isYamlDocumentMarker reports whether the line starts (---) or ends (...) a document.
+func isYamlDocumentMarker(line yamlLine) bool {
+	return line.indent == 0 && (line.content == "---" || line.content == "..." || strings.HasPrefix(line.content, "--- "))
+}
+
+func isYamlSequenceItem(content string) bool {
+	return content == "-" || strings.HasPrefix(content, "- ")
+}
+
+// parseBlock parses the node which starts at the next line, with the given indentation.
+// parentIndent is the indentation of the collection containing the node.
+func (parser *yamlParser) parseBlock(indent int, parentIndent int) (any, error) {
+	if parser.depth++; parser.depth > maxTreeDepth {
+		return nil, errors.New("exceeded max nesting depth")
+	}
+	defer func() { parser.depth-- }()
+	line, _ := parser.peek()
+	if isYamlSequenceItem(line.content) {
+		return parser.parseSequence(indent)
+	}
+	if _, _, isEntry, err := splitYamlEntry(line.content, line.number); err != nil {
+		return nil, err
+	} else if isEntry {
+		return parser.parseMapping(indent)
+	}
+	parser.consume()
+	node, err := parser.parseValue(line.content, line.number, parentIndent)
+	if err != nil {
+		return nil, err
+	}
+	if next, found := parser.peek(); found && next.indent > parentIndent && next.indent >= indent && !isYamlDocumentMarker(next) {
+		return nil, yamlErrorAt(next.number, "multi-line plain scalars are not supported")
+	}
+	return node, nil
+}
+
+func (parser *yamlParser) parseSequence(indent int) (any, error) {
+	items := make([]any, 0)
+	for {
+		line, found := parser.peek()
+		if !found || line.indent < indent || isYamlDocumentMarker(line) {
+			return items, nil
+		}
+		if line.indent > indent {
+			return nil, yamlErrorAt(line.number, "unexpected indentation")
+		}
+		if !isYamlSequenceItem(line.content) {
+			if _, _, isEntry, err := splitYamlEntry(line.content, line.number); err != nil {
+				return nil, err
+			} else if isEntry {
+				// a sequence which is the value of a mapping entry, with the same indentation as the mapping
+				return items, nil
+			}
+			return nil, yamlErrorAt(line.number, "expected a sequence item")
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(line.content, "-"), " ")
+		if rest == "" {
+			parser.consume()
+			item, err := parser.parseNested(indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+		// the item is parsed as if the dash was indentation
+		column := indent + len(line.content) - len(rest)
+		parser.replace(column, rest)
+		item, err := parser.parseBlock(column, indent)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+}
+
+// parseNested parses the value of entries and items whose value starts at the next line (or is null).
+func (parser *yamlParser) parseNested(indent int) (any, error) {
+	next, found := parser.peek()
+	if !found || isYamlDocumentMarker(next) || next.indent < indent {
+		return nil, nil
+	}
+	if next.indent > indent {
+		return parser.parseBlock(next.indent, indent)
+	}
+	return nil, nil
+}
+
+// splitYamlEntry splits mapping entries like `key: value` into their key and value.
+func splitYamlEntry(content string, number int) (string, string, bool, error) {
+	if content != "" && content[0] == '\t' {
+		return "", "", false, yamlErrorAt(number, "tabs are not allowed for indentation")
+	}
+	if content == "" || strings.ContainsAny(content[:1], "[{") || isYamlSequenceItem(content) {
+		return "", "", false, nil
+	}
+	if content[0] == '?' && (len(content) == 1 || content[1] == ' ') {
+		return "", "", false, yamlErrorAt(number, "complex mapping keys are not supported")
+	}
+	var key string
+	var rest string
+	if content[0] == '"' || content[0] == '\'' {
+		parsed, length, err := parseYamlQuoted(content, number)
+		if err != nil {
+			return "", "", false, err
+		}
+		rest = strings.TrimLeft(content[length:], " ")
+		if !strings.HasPrefix(rest, ":") || (len(rest) > 1 && rest[1] != ' ') {
+			return "", "", false, nil
+		}
+		key, rest = parsed, rest[1:]
+	} else {
+		separator := strings.Index(content, ": ")
+		if separator < 0 {
+			if !strings.HasSuffix(content, ":") {
+				return "", "", false, nil
+			}
+			separator = len(content) - 1
+		}
+		key, rest = strings.TrimRight(content[:separator], " "), content[separator+1:]
+		if key != "" && strings.ContainsAny(key[:1], "&*!") {
+			return "", "", false, yamlErrorAt(number, "anchors, aliases and tags are not supported")
+		}
+	}
+	return key, strings.TrimSpace(rest), true, nil
+}
+
+func (parser *yamlParser) parseMapping(indent int) (any, error) {
+	entries := make(treeMap, 0)
+	keys := make(map[string]struct{})
+	for {
+		line, found := parser.peek()
+		if !found || line.indent < indent || isYamlDocumentMarker(line) {
+			return entries, nil
+		}
+		if line.indent > indent {
+			return nil, yamlErrorAt(line.number, "unexpected indentation")
+		}
+		key, rest, isEntry, err := splitYamlEntry(line.content, line.number)
+		if err != nil {
+			return nil, err
+		}
+		if !isEntry {
+			return nil, yamlErrorAt(line.number, "expected a mapping entry")
+		}
+		if _, duplicate := keys[key]; duplicate {
+			return nil, yamlErrorAt(line.number, "duplicate key '%s'", key)
+		}
+		keys[key] = struct{}{}
+		parser.consume()
+		var value any
+		if rest == "" {
+			next, found := parser.peek()
+			if found && next.indent == indent && isYamlSequenceItem(next.content) {
+				value, err = parser.parseSequence(indent)
+			} else {
+				value, err = parser.parseNested(indent)
+			}
+		} else {
+			value, err = parser.parseValue(rest, line.number, indent)
+			if err == nil {
+				if next, found := parser.peek(); found && next.indent > indent {
+					err = yamlErrorAt(next.number, "unexpected indentation")
+					if _, _, isEntry, _ := splitYamlEntry(next.content, next.number); !isEntry && !isYamlSequenceItem(next.content) {
+						err = yamlErrorAt(next.number, "multi-line plain scalars are not supported")
+					}
+				}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, treeEntry{key: key, value: value})
+	}
+}
+
+// parseValue parses the value which is written in a single line, or a block scalar starting at the line.
+func (parser *yamlParser) parseValue(content string, number int, parentIndent int) (any, error) {
+	if content[0] == '|' || content[0] == '>' {
+		return parser.parseBlockScalar(content, number, parentIndent)
+	}
+	flow := &yamlFlowParser{content: content, number: number}
+	value, err := flow.parse(false)
+	if err != nil {
+		return nil, err
+	}
+	if rest := strings.TrimSpace(flow.content[flow.offset:]); rest != "" {
+		return nil, yamlErrorAt(number, "unexpected '%s'", rest)
+	}
+	return value, nil
+}
+
+// parseBlockScalar parses literal (|) and folded (>) scalars, whose lines are more indented than their parent.
+func (parser *yamlParser) parseBlockScalar(header string, number int, parentIndent int) (any, error) {
+	chomping, indentation := byte(0), 0
+	for _, c := range []byte(header[1:]) {
+		switch {
+		case (c == '-' || c == '+') && chomping == 0:
+			chomping = c
+		case c >= '1' && c <= '9' && indentation == 0:
+			indentation = int(c - '0')
+		default:
+			return nil, yamlErrorAt(number, "invalid block scalar header '%s'", header)
+		}
+	}
+	contentIndent := -1
+	if indentation != 0 {
+		contentIndent = parentIndent + indentation
+		if parentIndent < 0 {
+			contentIndent = indentation
+		}
+	}
+	var lines []string
+	for parser.pos < len(parser.raw) {
+		raw := strings.TrimRight(parser.raw[parser.pos], "\r")
+		trimmed := strings.TrimLeft(raw, " ")
+		indent := len(raw) - len(trimmed)
+		if trimmed == "" {
+			lines = append(lines, "")
+			parser.pos++
+			continue
+		}
+		if contentIndent < 0 {
+			if indent <= parentIndent {
+				break
+			}
+			contentIndent = indent
+		}
+		if indent < contentIndent {
+			break
+		}
+		lines = append(lines, raw[contentIndent:])
+		parser.pos++
+	}
+	// trailing empty lines are handled by chomping
+	trailing := 0
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+	var text string
+	if header[0] == '|' {
+		text = strings.Join(lines, "\n")
+	} else {
+		text = foldYamlLines(lines)
+	}
+	switch {
+	case len(lines) == 0:
+		text = ""
+		if chomping == '+' {
+			text = strings.Repeat("\n", trailing)
+		}
+	case chomping == '-':
+	case chomping == '+':
+		text += strings.Repeat("\n", trailing+1)
+	default:
+		text += "\n"
+	}
+	return text, nil
+}
+
+// foldYamlLines joins the lines of folded scalars with spaces, empty lines and more indented lines keep the line breaks.
+func foldYamlLines(lines []string) string {
+	var builder strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			previous := lines[i-1]
+			switch {
+			case line == "" || previous == "":
+				if line == "" {
+					builder.WriteString("\n")
+				}
+			case strings.HasPrefix(line, " ") || strings.HasPrefix(previous, " "):
+				builder.WriteString("\n")
+			default:
+				builder.WriteString(" ")
+			}
+		}
+		builder.WriteString(line)
+	}
+	return builder.String()
+}
+
+// parseYamlQuoted parses the quoted scalar at the start of the content, and returns its length.
+func parseYamlQuoted(content string, number int) (string, int, error) {
+	quote := content[0]
+	var builder strings.Builder
+	for i := 1; i < len(content); i++ {
+		c := content[i]
+		if quote == '\'' {
+			if c == '\'' {
+				if i+1 < len(content) && content[i+1] == '\'' {
+					builder.WriteByte('\'')
+					i++
+					continue
+				}
+				return builder.String(), i + 1, nil
+			}
+			builder.WriteByte(c)
+			continue
+		}
+		switch c {
+		case '"':
+			return builder.String(), i + 1, nil
+		case '\\':
+			if i+1 >= len(content) {
+				return "", 0, yamlErrorAt(number, "unterminated escape sequence")
+			}
+			i++
+			length := 0
+			switch content[i] {
+			case '0':
+				builder.WriteByte(0)
+			case 'a':
+				builder.WriteByte('\a')
+			case 'b':
+				builder.WriteByte('\b')
+			case 't', '\t':
+				builder.WriteByte('\t')
+			case 'n':
+				builder.WriteByte('\n')
+			case 'v':
+				builder.WriteByte('\v')
+			case 'f':
+				builder.WriteByte('\f')
+			case 'r':
+				builder.WriteByte('\r')
+			case 'e':
+				builder.WriteByte(0x1b)
+			case ' ', '"', '/', '\\':
+				builder.WriteByte(content[i])
+			case 'N':
+				builder.WriteRune('\u0085')
+			case '_':
+				builder.WriteRune(' ')
+			case 'L':
+				builder.WriteRune('\u2028')
+			case 'P':
+				builder.WriteRune('\u2029')
+			case 'x':
+				length = 2
+			case 'u':
+				length = 4
+			case 'U':
+				length = 8
+			default:
+				return "", 0, yamlErrorAt(number, "invalid escape sequence '\\%c'", content[i])
+			}
+			if length > 0 {
+				if i+length >= len(content) {
+					return "", 0, yamlErrorAt(number, "invalid escape sequence")
+				}
+				code, err := strconv.ParseUint(content[i+1:i+1+length], 16, 32)
+				if err != nil {
+					return "", 0, yamlErrorAt(number, "invalid escape sequence '\\%s'", content[i:i+1+length])
+				}
+				builder.WriteRune(rune(code))
+				i += length
+			}
+		default:
+			builder.WriteByte(c)
+		}
+	}
+	return "", 0, yamlErrorAt(number, "unterminated quoted scalar (multi-line quoted scalars are not supported)")
+}
+
+// yamlFlowParser parses the values written in a single line, like scalars and flow collections ([a, b] or {a: b}).
+type yamlFlowParser struct {
+	content string
+	offset  int
+	number  int
+	depth   int
+}
+
+func (flow *yamlFlowParser) skipSpaces() {
+	for flow.offset < len(flow.content) && flow.content[flow.offset] == ' ' {
+		flow.offset++
+	}
+}
+
+// parse parses the value at the current offset; inside collections, plain scalars end at flow indicators.
+func (flow *yamlFlowParser) parse(inCollection bool) (any, error) {
+	flow.skipSpaces()
+	if flow.offset >= len(flow.content) {
+		return nil, nil
+	}
+	rest := flow.content[flow.offset:]
+	switch rest[0] {
+	case '&', '*', '!':
+		return nil, yamlErrorAt(flow.number, "anchors, aliases and tags are not supported")
+	case '"', '\'':
+		value, length, err := parseYamlQuoted(rest, flow.number)
+		flow.offset += length
+		return value, err
+	case '[', '{':
+		if flow.depth++; flow.depth > maxTreeDepth {
+			return nil, yamlErrorAt(flow.number, "exceeded max nesting depth")
+		}
+		defer func() { flow.depth-- }()
+		flow.offset++
+		if rest[0] == '[' {
+			return flow.parseSequence()
+		}
+		return flow.parseMapping()
+	}
+	end := len(rest)
+	if inCollection {
+		for i := 0; i < len(rest); i++ {
+			if strings.IndexByte(",[]{}", rest[i]) >= 0 || (rest[i] == ':' && (i+1 == len(rest) || strings.IndexByte(" ,]}", rest[i+1]) >= 0)) {
+				end = i
+				break
+			}
+		}
+	}
+	flow.offset += end
+	return resolveYamlScalar(strings.TrimSpace(rest[:end])), nil
+}
+
+func (flow *yamlFlowParser) expect(c byte) bool {
+	flow.skipSpaces()
+	if flow.offset < len(flow.content) && flow.content[flow.offset] == c {
+		flow.offset++
+		return true
+	}
+	return false
+}
+
+func (flow *yamlFlowParser) parseSequence() (any, error) {
+	items := make([]any, 0)
+	for {
+		if flow.expect(']') {
+			return items, nil
+		}
+		item, err := flow.parse(true)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if !flow.expect(',') {
+			if flow.expect(']') {
+				return items, nil
+			}
+			return nil, yamlErrorAt(flow.number, "expected ',' or ']' in flow sequence (multi-line flow collections are not supported)")
+		}
+	}
+}
+
+func (flow *yamlFlowParser) parseMapping() (any, error) {
+	entries := make(treeMap, 0)
+	for {
+		if flow.expect('}') {
+			return entries, nil
+		}
+		key, err := flow.parse(true)
+		if err != nil {
+			return nil, err
+		}
+		var value any
+		if flow.expect(':') {
+			if value, err = flow.parse(true); err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, treeEntry{key: yamlKey(key), value: value})
+		if !flow.expect(',') {
+			if flow.expect('}') {
+				return entries, nil
+			}
+			return nil, yamlErrorAt(flow.number, "expected ',' or '}' in flow mapping (multi-line flow collections are not supported)")
+		}
+	}
+}
+
+// yamlKey converts the resolved keys of flow mappings back into strings, just like the keys of block mappings.
+func yamlKey(key any) string {
+	switch value := key.(type) {
+	case string:
+		return value
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(value)
+	}
+}
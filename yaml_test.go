@@ -0,0 +1,197 @@
+package stgin
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type yamlAddress struct {
+	City string `yaml:"city"`
+	Zip  string `yaml:"zip,omitempty"`
+}
+
+type yamlConfig struct {
+	Name      string            `yaml:"name"`
+	Port      int               `yaml:"port"`
+	Debug     bool              `yaml:"debug"`
+	Ratio     float64           `yaml:"ratio"`
+	Timeout   time.Duration     `yaml:"timeout"`
+	Tags      []string          `yaml:"tags"`
+	Addresses []yamlAddress     `yaml:"addresses"`
+	Labels    map[string]string `yaml:"labels"`
+	Note      string            `yaml:"note"`
+	Secret    string            `yaml:"-"`
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	config := yamlConfig{
+		Name:      "api: v1",
+		Port:      8080,
+		Debug:     true,
+		Ratio:     2,
+		Timeout:   90 * time.Second,
+		Tags:      []string{"a", "true", "- b"},
+		Addresses: []yamlAddress{{City: "Tehran", Zip: "123"}, {City: "Berlin"}},
+		Labels:    map[string]string{"b": "2", "a": ""},
+		Note:      "line 1\nline 2",
+		Secret:    "hidden",
+	}
+	encoded, err := YAMLCodec{}.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `name: "api: v1"
+port: 8080
+debug: true
+ratio: 2.0
+timeout: 1m30s
+tags:
+  - a
+  - "true"
+  - "- b"
+addresses:
+  - city: Tehran
+    zip: "123"
+  - city: Berlin
+labels:
+  a: ""
+  b: "2"
+note: "line 1\nline 2"
+`
+	if string(encoded) != expected {
+		t.Fatalf("unexpected yaml:\n%s", encoded)
+	}
+	var decoded yamlConfig
+	if err = (YAMLCodec{}).Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	config.Secret = ""
+	if !reflect.DeepEqual(decoded, config) {
+		t.Fatalf("expected %+v, got %+v", config, decoded)
+	}
+}
+
+func TestYAMLDecoding(t *testing.T) {
+	document := `
+# the service
+---
+name: 'it''s'   # comment
+port: 0x1F
+ratio: .5
+tags: [a, "b, c", 'd']
+labels: {x: 1, y: null}
+addresses:
+- city: Tehran
+  zip: ~
+-
+  city: Berlin
+note: |
+  first
+    indented
+
+  last
+timeout: 2s
+...
+`
+	var decoded yamlConfig
+	if err := (YAMLCodec{}).Unmarshal([]byte(document), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Name != "it's" || decoded.Port != 31 || decoded.Ratio != .5 || decoded.Timeout != 2*time.Second {
+		t.Fatalf("scalars were not decoded, got %+v", decoded)
+	}
+	if len(decoded.Tags) != 3 || decoded.Tags[1] != "b, c" {
+		t.Fatalf("flow sequence was not decoded, got %q", decoded.Tags)
+	}
+	if !reflect.DeepEqual(decoded.Labels, map[string]string{"x": "1", "y": ""}) {
+		t.Fatalf("flow mapping was not decoded, got %v", decoded.Labels)
+	}
+	if !reflect.DeepEqual(decoded.Addresses, []yamlAddress{{City: "Tehran"}, {City: "Berlin"}}) {
+		t.Fatalf("block sequence was not decoded, got %v", decoded.Addresses)
+	}
+	if decoded.Note != "first\n  indented\n\nlast\n" {
+		t.Fatalf("literal scalar was not decoded, got %q", decoded.Note)
+	}
+
+	var generic any
+	if err := (YAMLCodec{}).Unmarshal([]byte("a:\n  - 1\n  - [2.5, {k: v}]\nb: >-\n  folded\n  text\n"), &generic); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{"a": []any{int64(1), []any{2.5, map[string]any{"k": "v"}}}, "b": "folded text"}
+	if !reflect.DeepEqual(generic, expected) {
+		t.Fatalf("expected %v, got %v", expected, generic)
+	}
+}
+
+func TestYAMLQuoting(t *testing.T) {
+	document := `a: 'it''s: #not a comment'
+b: "tab\there \u00e9 \x41 \"q\""
+'quoted key': 1
+"k: v": 2
+c: "# not a comment"  # a comment
+`
+	var decoded map[string]any
+	if err := (YAMLCodec{}).Unmarshal([]byte(document), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{"a": "it's: #not a comment", "b": "tab\there \u00e9 A \"q\"", "quoted key": int64(1), "k: v": int64(2), "c": "# not a comment"}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Fatalf("expected %v, got %v", expected, decoded)
+	}
+	for _, value := range []string{"yes", "null", "~", "123", "1e3", "0x10", "a: b", "#x", " lead", "- x", "it's", `q"`, "tab\t", "multi\nline", "é"} {
+		encoded, _ := YAMLCodec{}.Marshal(map[string]string{"k": value})
+		var roundTrip map[string]string
+		if err := (YAMLCodec{}).Unmarshal(encoded, &roundTrip); err != nil || roundTrip["k"] != value {
+			t.Errorf("%q was encoded as %q, which was decoded as %q (%v)", value, encoded, roundTrip["k"], err)
+		}
+	}
+}
+
+func TestYAMLBlockScalarsAndSequences(t *testing.T) {
+	cases := map[string]any{
+		"a: >+\n  one\n  two\n\n  three\n\nb: 1\n": map[string]any{"a": "one two\nthree\n\n", "b": int64(1)},
+		"a: |2\n    indented\n  less\n":            map[string]any{"a": "  indented\nless\n"},
+		"a: |-\n  x\n\n":                           map[string]any{"a": "x"},
+		"a: >\n  folded\n    more\n  back\n":       map[string]any{"a": "folded\n  more\nback\n"},
+		"- - a\n  - b\n- - - c\n-\n  - d\n":        []any{[]any{"a", "b"}, []any{[]any{"c"}}, []any{"d"}},
+		"a:\n- 1\n- [2, [3]]\nb: 2\n":              map[string]any{"a": []any{int64(1), []any{int64(2), []any{int64(3)}}}, "b": int64(2)},
+		"- a: 1\n  b:\n  - x\n- []\n":              []any{map[string]any{"a": int64(1), "b": []any{"x"}}, []any{}},
+	}
+	for document, expected := range cases {
+		var decoded any
+		if err := (YAMLCodec{}).Unmarshal([]byte(document), &decoded); err != nil || !reflect.DeepEqual(decoded, expected) {
+			t.Errorf("expected %#v for %q, got %#v (%v)", expected, document, decoded, err)
+		}
+	}
+}
+
+func TestYAMLErrors(t *testing.T) {
+	cases := map[string]string{
+		"port: abc":                    "port",
+		"a: 1\na: 2":                   "duplicate key",
+		"a: &anchor 1":                 "anchors",
+		"a: [1, 2":                     "flow sequence",
+		"a: 1\n  b: 2":                 "indentation",
+		"a: \"unterminated":            "unterminated",
+		"a: 1\n---\nb: 2":              "multiple documents",
+		"a: *alias":                    "aliases",
+		"&anchor a: 1":                 "anchors",
+		"a: !!str 1":                   "tags",
+		"%YAML 1.2\n---\na: 1":         "directives",
+		"? a\n: 1":                     "complex mapping keys",
+		"a:\n\tb: 1":                   "line 2: tabs",
+		"a: plain\n  continued":        "line 2: multi-line plain scalars",
+		"a: \"multi\n  line\"":         "multi-line quoted scalars",
+		"a: [1,\n  2]":                 "multi-line flow collections",
+		"addresses:\n  - city: [1, 2]": "addresses[0].city",
+	}
+	for document, message := range cases {
+		var decoded yamlConfig
+		err := (YAMLCodec{}).Unmarshal([]byte(document), &decoded)
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("expected an error containing %q for %q, got %v", message, document, err)
+		}
+	}
+}